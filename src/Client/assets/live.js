@@ -0,0 +1,60 @@
+// Keeps the server list up to date while it is open: whenever a server is
+// created, modified or deleted, by anyone, the list is read again in place,
+// keeping the same search, order and page, and any servers ticked. Without
+// JavaScript, or EventSource, the list is simply as it was when loaded.
+(function () {
+    "use strict";
+
+    var list = document.getElementById("servers");
+    var live = document.getElementById("live");
+    if (!list || !window.EventSource || !window.fetch || !window.DOMParser) {
+        return;
+    }
+
+    function refresh() {
+        // Marked as a refresh, so that messages for the next page are kept for it
+        fetch(window.location.href, {
+            credentials: "same-origin",
+            headers: {"X-Sadmin-Refresh": "1"}
+        }).then(function (response) {
+            // If signed out, say, leave the list as it is
+            if (!response.ok || response.redirected) {
+                throw new Error(response.statusText);
+            }
+            return response.text();
+        }).then(function (html) {
+            var fresh = new DOMParser().parseFromString(html, "text/html").getElementById("servers");
+            if (!fresh) {
+                return;
+            }
+            var ticked = {};
+            list.querySelectorAll("input[type=checkbox]:checked").forEach(function (box) {
+                ticked[box.value] = true;
+            });
+            fresh.querySelectorAll("input[type=checkbox]").forEach(function (box) {
+                box.checked = ticked[box.value] === true;
+            });
+            list.replaceWith(fresh);
+            list = fresh;
+        }).catch(function () {});
+    }
+
+    // Several changes close together, as from a bulk action, need only one refresh
+    var pending;
+    function changed() {
+        clearTimeout(pending);
+        pending = setTimeout(refresh, 250);
+    }
+
+    var source = new EventSource("/events");
+    ["server.created", "server.modified", "server.deleted", "reset"].forEach(function (type) {
+        source.addEventListener(type, changed);
+    });
+    source.onopen = function () {
+        live.hidden = false;
+    };
+    // The browser reconnects by itself, resuming after the last event received
+    source.onerror = function () {
+        live.hidden = true;
+    };
+})();
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"admin-server/identity"
+	"admin-server/sadmin"
+	"admin-server/validation"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Bulk actions on the servers selected in the server list
+const (
+	bulkDelete = "delete"
+	bulkStatus = "status"
+	bulkTags   = "tags"
+)
+
+// Most servers acted on at once
+const maxBulk = 100
+
+// A bulkItem is a selected server, and what became of it.
+type bulkItem struct {
+	ID             int64
+	Name           string
+	NoLongerExists bool
+	Done           bool
+	Failure        string
+}
+
+type bulkPageVars struct {
+	Action    string
+	Status    string
+	Tags      []string
+	Items     []bulkItem
+	Confirmed bool
+	Invalid   string
+	Problem   *sadmin.Error
+	CSRFToken string
+}
+
+// Description says what the action does, for the confirmation page.
+func (p bulkPageVars) Description() string {
+	switch p.Action {
+	case bulkDelete:
+		return "Delete"
+	case bulkStatus:
+		return "Set the status to '" + p.Status + "' of"
+	}
+	return "Add the tags '" + strings.Join(p.Tags, ", ") + "' to"
+}
+
+// Counts summarize what became of the servers.
+func (p bulkPageVars) Succeeded() (n int) {
+	for _, item := range p.Items {
+		if item.Done {
+			n++
+		}
+	}
+	return n
+}
+
+func (p bulkPageVars) Gone() (n int) {
+	for _, item := range p.Items {
+		if item.NoLongerExists {
+			n++
+		}
+	}
+	return n
+}
+
+func (p bulkPageVars) Failed() (n int) {
+	return len(p.Items) - p.Succeeded() - p.Gone()
+}
+
+// readBulk reads the action and the selected servers from the request,
+// describing what is wrong with them in Invalid.
+func readBulk(r *http.Request) bulkPageVars {
+	r.ParseForm()
+	page := bulkPageVars{Action: r.Form.Get("action"), CSRFToken: csrfToken(r)}
+
+	names := r.Form["name"]
+	for i, v := range r.Form["id"] {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		item := bulkItem{ID: id}
+		if len(names) == len(r.Form["id"]) {
+			item.Name = names[i]
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	switch page.Action {
+	case bulkDelete:
+		if !sessionFromRequest(r).hasRole(identity.RoleAdmin) {
+			page.Invalid = "Only administrators may delete servers."
+		}
+	case bulkStatus:
+		var violations []validation.Violation
+		if page.Status, violations = validation.Status(r.Form.Get("status")); len(violations) > 0 {
+			page.Invalid = violations[0].Message
+		}
+	case bulkTags:
+		var violations []validation.Violation
+		page.Tags, violations = validation.Tags(strings.FieldsFunc(strings.Join(r.Form["tags"], " "), func(c rune) bool {
+			return c == ',' || c == ' '
+		}))
+		if len(violations) > 0 {
+			page.Invalid = violations[0].Message
+		} else if len(page.Tags) == 0 {
+			page.Invalid = "Enter the tags to add, separated by commas or spaces."
+		}
+	default:
+		page.Invalid = "Choose an action."
+	}
+
+	switch {
+	case len(page.Items) == 0:
+		page.Invalid = "Select the servers to act on."
+	case len(page.Items) > maxBulk:
+		page.Invalid = fmt.Sprintf("Select at most %d servers at a time.", maxBulk)
+	}
+	return page
+}
+
+// showBulkForm asks the user to confirm the action on the selected servers.
+func showBulkForm(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+	page := readBulk(request)
+	if page.Invalid != "" {
+		renderStatus(writer, request, http.StatusBadRequest, "bulk.gohtml", page)
+		return
+	}
+
+	c := api(request)
+	for i, item := range page.Items {
+		s, err := c.Get(request.Context(), item.ID)
+		if errors.Is(err, sadmin.ErrNotFound) {
+			page.Items[i].NoLongerExists = true
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(request.Context(), "showBulkForm - Error on Get", "error", err)
+			page.Problem = problemFor(err)
+			renderProblem(writer, request, page.Problem, "bulk.gohtml", page)
+			return
+		}
+		page.Items[i].Name = s.Name
+	}
+	render(writer, request, "bulk.gohtml", page)
+}
+
+// bulkAction acts on each of the selected servers in turn, showing what became of each.
+func bulkAction(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+	page := readBulk(request)
+	if page.Invalid != "" {
+		renderStatus(writer, request, http.StatusBadRequest, "bulk.gohtml", page)
+		return
+	}
+	page.Confirmed = true
+
+	// The client waits as long as the REST server asks when throttled, so if it
+	// is still throttled, so would the rest be, and they are not attempted
+	c := api(request)
+	var throttled error
+	for i := range page.Items {
+		item := &page.Items[i]
+		var err error
+		switch {
+		case throttled != nil:
+			err = throttled
+		case page.Action == bulkDelete:
+			err = c.Delete(request.Context(), item.ID)
+		case page.Action == bulkStatus:
+			_, err = c.SetStatus(request.Context(), item.ID, page.Status)
+		case page.Action == bulkTags:
+			_, err = c.AddTags(request.Context(), item.ID, page.Tags)
+		}
+		var e *sadmin.Error
+		if errors.As(err, &e) && e.Status == http.StatusTooManyRequests {
+			throttled = err
+		}
+
+		switch {
+		case err == nil:
+			item.Done = true
+		case errors.Is(err, sadmin.ErrNotFound):
+			item.NoLongerExists = true
+		default:
+			slog.ErrorContext(request.Context(), "bulkAction - Error on "+page.Action, "error", err, "id", item.ID)
+			p := problemFor(err)
+			item.Failure = p.Title
+			if p.Detail != "" {
+				item.Failure = p.Detail
+			}
+		}
+	}
+
+	slog.InfoContext(request.Context(), "Bulk Action on Server Entries", "action", page.Action,
+		"succeeded", page.Succeeded(), "no_longer_exist", page.Gone(), "failed", page.Failed())
+
+	render(writer, request, "bulk.gohtml", page)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"admin-server/identity"
+	"admin-server/sadmin"
+)
+
+// fakeInventory serves a REST server holding the servers, in place of the
+// configured one. Server 8 is always throttled, and server 9 always fails.
+func fakeInventory(t *testing.T, inventory map[string]*sadmin.Server) {
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/servers/"), "/tags")
+		if id == "8" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"title":"Too Many Requests","status":429,"code":"rate_limited"}`))
+			return
+		}
+		if id == "9" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"title":"Internal Server Error","status":500,"code":"internal_error","detail":"Database unavailable"}`))
+			return
+		}
+		s, ok := inventory[id]
+		if !ok {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title":"Not Found","status":404,"code":"not_found"}`))
+			return
+		}
+		switch r.Method {
+		case "PATCH":
+			json.NewDecoder(r.Body).Decode(s)
+		case "POST":
+			var in sadmin.Server
+			json.NewDecoder(r.Body).Decode(&in)
+			s.Tags = append(s.Tags, in.Tags...)
+		case "DELETE":
+			delete(inventory, id)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	}))
+	t.Cleanup(remote.Close)
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+}
+
+func bulkRequest(t *testing.T, role, method string, form url.Values) *httptest.ResponseRecorder {
+	s := sessions.create("user", role)
+	t.Cleanup(func() { sessions.destroy(s.ID) })
+
+	var req *http.Request
+	if method == "GET" {
+		req = httptest.NewRequest(method, "/bulk?"+form.Encode(), nil)
+	} else {
+		form.Set(csrfFieldName, s.CSRFToken)
+		req = httptest.NewRequest(method, "/bulk", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: s.ID})
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBulkInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		role     string
+		form     url.Values
+		expected string
+	}{
+		{identity.RoleEditor, url.Values{"action": {"status"}, "status": {"active"}}, "Select the servers"},
+		{identity.RoleEditor, url.Values{"action": {"delete"}, "id": {"1"}}, "Only administrators"},
+		{identity.RoleEditor, url.Values{"action": {"status"}, "status": {"retired"}, "id": {"1"}}, "The status must be one of"},
+		{identity.RoleEditor, url.Values{"action": {"tags"}, "tags": {" , "}, "id": {"1"}}, "Enter the tags"},
+		{identity.RoleEditor, url.Values{"action": {"rename"}, "id": {"1"}}, "Choose an action"},
+	} {
+		rr := bulkRequest(t, tc.role, "GET", tc.form)
+		if body := rr.Body.String(); rr.Code != http.StatusBadRequest || !strings.Contains(body, tc.expected) {
+			t.Errorf("%v - Expected '%s'. Got %d %s", tc.form, tc.expected, rr.Code, body)
+		}
+	}
+
+	if rr := bulkRequest(t, identity.RoleViewer, "GET", url.Values{"action": {"status"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected viewers to be forbidden. Got %d", rr.Code)
+	}
+}
+
+func TestBulkConfirm(t *testing.T) {
+	fakeInventory(t, map[string]*sadmin.Server{"1": {ID: 1, Name: "a.example.com"}, "2": {ID: 2, Name: "b.example.com"}})
+
+	rr := bulkRequest(t, identity.RoleAdmin, "GET", url.Values{"action": {"delete"}, "id": {"1", "2", "3"}})
+
+	body := rr.Body.String()
+	for _, expected := range []string{"Delete 3 servers?", "a.example.com", "b.example.com", "Server 3 (no longer exists)",
+		`name="action" value="delete"`, `value="Confirm"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected '%s'. Got %d %s", expected, rr.Code, body)
+		}
+	}
+}
+
+func TestBulkActions(t *testing.T) {
+	inventory := map[string]*sadmin.Server{
+		"1": {ID: 1, Name: "a.example.com", Status: "active", Tags: []string{"db"}},
+		"2": {ID: 2, Name: "b.example.com", Status: "active", Tags: []string{}},
+	}
+	fakeInventory(t, inventory)
+
+	ids := []string{"1", "2", "3", "9"}
+	rr := bulkRequest(t, identity.RoleEditor, "POST", url.Values{"action": {"status"}, "status": {"maintenance"}, "id": ids})
+	if body := rr.Body.String(); !strings.Contains(body, "2 done, 1 no longer existed, <span class=\"problem\">1 failed</span>") ||
+		!strings.Contains(body, "Database unavailable") {
+		t.Errorf("Expected a summary of each server. Got %s", body)
+	}
+	if inventory["1"].Status != "maintenance" || inventory["2"].Status != "maintenance" {
+		t.Errorf("Expected the status to be set. Got %+v %+v", inventory["1"], inventory["2"])
+	}
+
+	bulkRequest(t, identity.RoleEditor, "POST", url.Values{"action": {"tags"}, "tags": {"rack-12"}, "id": {"1", "2"}})
+	if strings.Join(inventory["1"].Tags, ",") != "db,rack-12" || strings.Join(inventory["2"].Tags, ",") != "rack-12" {
+		t.Errorf("Expected the tag to be added. Got %v %v", inventory["1"].Tags, inventory["2"].Tags)
+	}
+
+	// Once throttled, the rest are not attempted
+	rr = bulkRequest(t, identity.RoleEditor, "POST", url.Values{"action": {"status"}, "status": {"active"}, "id": {"8", "1"}})
+	if body := rr.Body.String(); inventory["1"].Status != "maintenance" || !strings.Contains(body, "2 failed") ||
+		!strings.Contains(body, "The inventory is busy") {
+		t.Errorf("Expected both servers to fail as throttled. Got %+v %s", inventory["1"], body)
+	}
+
+	rr = bulkRequest(t, identity.RoleAdmin, "POST", url.Values{"action": {"delete"}, "id": {"1", "2"}})
+	if len(inventory) != 0 || !strings.Contains(rr.Body.String(), "2 done.") {
+		t.Errorf("Expected the servers to be deleted. Got %v %s", inventory, rr.Body.String())
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	serverconfig "admin-server/config"
+	"admin-server/settings"
+)
+
+// The configuration of the web client. Each setting is taken from, in increasing
+// order of precedence: its default, a YAML config file (named by the -config flag
+// or CONFIG_FILE), its environment variable, and its command-line flag, as
+// described in package settings. The sections it has in common with the REST
+// server are those of the server's config package.
+
+type remoteConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+type authConfig struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+type sessionConfig struct {
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	AbsoluteTimeout time.Duration `yaml:"absolute_timeout"`
+}
+
+type oidcConfig struct {
+	IssuerURL    string `yaml:"issuer_url"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	RedirectURL  string `yaml:"redirect_url"`
+	GroupsClaim  string `yaml:"groups_claim"`
+	RoleMap      string `yaml:"role_map"`
+}
+
+type config struct {
+	Port               string                `yaml:"port"`
+	TLS                serverconfig.TLS      `yaml:"tls"`
+	Timeouts           serverconfig.Timeouts `yaml:"timeouts"`
+	Log                serverconfig.Log      `yaml:"log"`
+	Tracing            serverconfig.Tracing  `yaml:"tracing"`
+	Templates          string                `yaml:"templates"`
+	Assets             string                `yaml:"assets"`
+	DevMode            bool                  `yaml:"dev_mode"`
+	Remote             remoteConfig          `yaml:"remote"`
+	Auth               authConfig            `yaml:"auth"`
+	IdentitySigningKey string                `yaml:"identity_signing_key"`
+	Session            sessionConfig         `yaml:"session"`
+	OIDC               oidcConfig            `yaml:"oidc"`
+}
+
+func defaultConfig() config {
+	return config{
+		Port: "8200",
+		TLS: serverconfig.TLS{
+			Cert: "../../certificates/WEB-server.pem",
+			Key:  "../../certificates/WEB-server-private-key.pem",
+		},
+		Timeouts: serverconfig.Timeouts{
+			Read:          15 * time.Second,
+			Write:         30 * time.Second,
+			Idle:          2 * time.Minute,
+			ShutdownGrace: 20 * time.Second,
+		},
+		Log:    serverconfig.Log{Level: "info", Format: "json"},
+		Remote: remoteConfig{Port: "8100"},
+		Session: sessionConfig{
+			IdleTimeout:     defaultSessionIdleTimeout,
+			AbsoluteTimeout: defaultSessionAbsoluteTimeout,
+		},
+		OIDC: oidcConfig{GroupsClaim: "groups"},
+	}
+}
+
+// conf is the configuration in effect.
+var conf = defaultConfig()
+
+// Settings are the settings of the web client.
+func (c *config) Settings() []settings.Setting {
+	var s []settings.Setting
+	s = append(s, settings.Flag("port", "PORT", settings.String(&c.Port), "port to serve on"))
+	s = append(s, c.TLS.Settings()...)
+	s = append(s, c.Timeouts.Settings()...)
+	s = append(s, c.Log.Settings()...)
+	s = append(s, c.Tracing.Settings()...)
+	return append(s, []settings.Setting{
+		settings.Flag("templates", "TEMPLATES_DIR", settings.String(&c.Templates), "directory of page templates to use in place of the built-in ones"),
+		settings.Flag("assets", "ASSETS_DIR", settings.String(&c.Assets), "directory of static assets to use in place of the built-in ones"),
+		settings.Flag("dev-mode", "DEV_MODE", settings.Bool(&c.DevMode), "parse the page templates again for each page"),
+		settings.Flag("remote-host", "REMOTE_HOST", settings.String(&c.Remote.Host), "REST server host"),
+		settings.Flag("remote-port", "REMOTE_PORT", settings.String(&c.Remote.Port), "REST server port"),
+		settings.Flag("remote-user", "REMOTE_AUTH_USER", settings.String(&c.Remote.User), "REST server API user"),
+		settings.Secret("REMOTE_AUTH_PASSWORD", settings.String(&c.Remote.Password)),
+		settings.Flag("auth-user", "AUTH_USER", settings.String(&c.Auth.User), "local administrator (blank for none)"),
+		settings.Secret("AUTH_PASSWORD", settings.String(&c.Auth.Password)),
+		settings.Secret("IDENTITY_SIGNING_KEY", settings.String(&c.IdentitySigningKey)),
+		settings.Flag("session-idle-timeout", "SESSION_IDLE_TIMEOUT", settings.Duration(&c.Session.IdleTimeout), "session idle timeout"),
+		settings.Flag("session-absolute-timeout", "SESSION_ABSOLUTE_TIMEOUT", settings.Duration(&c.Session.AbsoluteTimeout), "session absolute timeout"),
+		settings.Flag("oidc-issuer-url", "OIDC_ISSUER_URL", settings.String(&c.OIDC.IssuerURL), "single sign-on issuer URL (blank for none)"),
+		settings.Flag("oidc-client-id", "OIDC_CLIENT_ID", settings.String(&c.OIDC.ClientID), "single sign-on client ID"),
+		settings.Secret("OIDC_CLIENT_SECRET", settings.String(&c.OIDC.ClientSecret)),
+		settings.Flag("oidc-redirect-url", "OIDC_REDIRECT_URL", settings.String(&c.OIDC.RedirectURL), "single sign-on callback URL"),
+		settings.Flag("oidc-groups-claim", "OIDC_GROUPS_CLAIM", settings.String(&c.OIDC.GroupsClaim), "ID token claim listing groups"),
+		settings.Flag("oidc-role-map", "OIDC_ROLE_MAP", settings.String(&c.OIDC.RoleMap), "group to role mapping, e.g. 'ops=editor'"),
+	}...)
+}
+
+// loadConfig returns the configuration for the command-line arguments
+// (excluding the program name), and the environment.
+func loadConfig(name string, args []string) (*config, error) {
+	c := defaultConfig()
+	if err := settings.Load(name, args, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// validate checks that the configuration is complete and consistent.
+func (c *config) validate() error {
+	var v settings.Validator
+
+	v.Port("port (PORT)", c.Port)
+	c.TLS.Validate(&v)
+	c.Timeouts.Validate(&v)
+	c.Log.Validate(&v)
+	c.Tracing.Validate(&v)
+	v.Directory("templates (TEMPLATES_DIR)", c.Templates)
+	if c.DevMode && c.Templates == "" {
+		// The built-in templates cannot change, so need not be parsed again
+		v.Errorf("dev_mode (DEV_MODE) needs templates (TEMPLATES_DIR), the directory of templates being worked on")
+	}
+	v.Directory("assets (ASSETS_DIR)", c.Assets)
+	v.Required("remote.host (REMOTE_HOST)", c.Remote.Host)
+	v.Port("remote.port (REMOTE_PORT)", c.Remote.Port)
+	v.Required("remote.user (REMOTE_AUTH_USER)", c.Remote.User)
+	v.Required("remote.password (REMOTE_AUTH_PASSWORD)", c.Remote.Password)
+	if c.Auth.User != "" {
+		v.Required("auth.password (AUTH_PASSWORD)", c.Auth.Password)
+	}
+	if k := c.IdentitySigningKey; k != "" && len(k) < 32 {
+		v.Errorf("identity_signing_key (IDENTITY_SIGNING_KEY) must be at least 32 characters")
+	}
+	v.Positive("session.idle_timeout (SESSION_IDLE_TIMEOUT)", c.Session.IdleTimeout)
+	v.Positive("session.absolute_timeout (SESSION_ABSOLUTE_TIMEOUT)", c.Session.AbsoluteTimeout)
+	if c.OIDC.IssuerURL != "" {
+		v.Required("oidc.client_id (OIDC_CLIENT_ID)", c.OIDC.ClientID)
+		v.URL("oidc.redirect_url (OIDC_REDIRECT_URL)", c.OIDC.RedirectURL, "https")
+		if _, err := parseRoleMap(c.OIDC.RoleMap); err != nil {
+			v.Errorf("oidc.role_map (OIDC_ROLE_MAP): %v", err)
+		}
+	} else if c.Auth.User == "" {
+		v.Errorf("either auth.user (AUTH_USER) or oidc.issuer_url (OIDC_ISSUER_URL) is required")
+	}
+	return v.Err()
+}
+
+// print writes the configuration as YAML, with any secrets redacted.
+func (c *config) print(w io.Writer) error {
+	return settings.Print(w, c)
+}
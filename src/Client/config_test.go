@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, contents string, mode os.FileMode) string {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := ioutil.WriteFile(path, []byte(contents), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, "port: \"9000\"\nremote:\n  host: file-host\n  user: file-user\nsession:\n  idle_timeout: 5m\n", 0644)
+	t.Setenv("REMOTE_HOST", "env-host")
+
+	c, err := loadConfig("test", []string{"-config", path, "-remote-user", "flag-user", "-session-absolute-timeout", "1h"})
+	if err != nil {
+		t.Fatalf("Error on loadConfig: %v", err)
+	}
+	if c.Port != "9000" {
+		t.Errorf("Expected port from file. Got '%s'", c.Port)
+	}
+	if c.Remote.Host != "env-host" {
+		t.Errorf("Expected host from environment. Got '%s'", c.Remote.Host)
+	}
+	if c.Remote.User != "flag-user" {
+		t.Errorf("Expected user from flag. Got '%s'", c.Remote.User)
+	}
+	if c.Remote.Port != "8100" {
+		t.Errorf("Expected default remote port. Got '%s'", c.Remote.Port)
+	}
+	if c.Session.IdleTimeout != 5*time.Minute || c.Session.AbsoluteTimeout != time.Hour {
+		t.Errorf("Expected timeouts of 5m and 1h. Got %v and %v", c.Session.IdleTimeout, c.Session.AbsoluteTimeout)
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	if _, err := loadConfig("test", []string{"-config", writeConfig(t, "prot: 9000\n", 0644)}); err == nil {
+		t.Error("Unknown setting accepted!")
+	}
+	if _, err := loadConfig("test", []string{"-config", writeConfig(t, "auth:\n  password: secret\n", 0644)}); err == nil {
+		t.Error("World-readable secrets accepted!")
+	}
+	if _, err := loadConfig("test", []string{"-config", writeConfig(t, "auth:\n  password: secret\n", 0600)}); err != nil {
+		t.Errorf("Private secrets refused: %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	c := defaultConfig()
+	c.Port = "http"
+	c.TLS.Cert = "/nonexistent/cert.pem"
+	c.IdentitySigningKey = "short"
+	c.Session.IdleTimeout = 0
+	c.DevMode = true
+
+	err := c.validate()
+	if err == nil {
+		t.Fatal("Invalid configuration passed!")
+	}
+	for _, expected := range []string{"port (PORT)", "tls.cert (TLS_CERT)", "remote.host (REMOTE_HOST)",
+		"identity_signing_key", "session.idle_timeout", "auth.user (AUTH_USER)", "dev_mode (DEV_MODE)"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error for %s. Got:\n%v", expected, err)
+		}
+	}
+}
+
+func TestConfigPrintRedactsSecrets(t *testing.T) {
+	c := defaultConfig()
+	c.Remote.User = "remote_user"
+	c.Remote.Password = "remotepass"
+
+	var b bytes.Buffer
+	if err := c.print(&b); err != nil {
+		t.Fatalf("Error on print: %v", err)
+	}
+	if strings.Contains(b.String(), "remotepass") || !strings.Contains(b.String(), "REDACTED") {
+		t.Errorf("Expected password to be redacted. Got:\n%s", b.String())
+	}
+	if !strings.Contains(b.String(), "idle_timeout: 20m0s") {
+		t.Errorf("Expected readable timeouts. Got:\n%s", b.String())
+	}
+	if c.Remote.Password != "remotepass" {
+		t.Error("print changed the configuration!")
+	}
+}
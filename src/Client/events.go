@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"admin-server/sadmin"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// streamClient makes requests which last as long as their context, where
+// client would time them out.
+var streamClient = &http.Client{Transport: client.Transport}
+
+// A comment is sent this often, so that proxies keep quiet streams open, and
+// the stream ends soon after its session does
+var keepAliveInterval = 15 * time.Second
+
+// How long browsers wait before reconnecting, in milliseconds
+const reconnectDelay = 3000
+
+// withStreams returns a context carrying streams, which is cancelled to end
+// the event streams of requests made in the context.
+func withStreams(ctx, streams context.Context) context.Context {
+	return context.WithValue(ctx, streamsContextKey, streams)
+}
+
+// eventsHandler relays the REST server's stream of changes to the inventory
+// to the browser, for the server list to keep itself up to date, for as long
+// as the user stays signed in.
+func eventsHandler(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+	s := sessionFromRequest(request)
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
+	if streams, ok := ctx.Value(streamsContextKey).(context.Context); ok {
+		stop := context.AfterFunc(streams, cancel)
+		defer stop()
+	}
+
+	// The stream outlasts the server's write timeout
+	rc := http.NewResponseController(writer)
+	rc.SetWriteDeadline(time.Time{})
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+	fmt.Fprintf(writer, "retry: %d\n\n", reconnectDelay)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	c := api(request)
+	c.HTTPClient = streamClient
+	events := make(chan sadmin.Event)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Watch(ctx, request.Header.Get("Last-Event-ID"), func(e sadmin.Event) error {
+			select {
+			case events <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e := <-events:
+			fmt.Fprintf(writer, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+		case <-keepAlive.C:
+			// Being open is not being active, so does not keep the session alive.
+			// Once it is gone, the browser's reconnection is refused.
+			if !sessions.active(s.ID) {
+				return
+			}
+			fmt.Fprint(writer, ": keep-alive\n\n")
+		case err := <-done:
+			// The browser reconnects, after reconnectDelay
+			if err != nil && ctx.Err() == nil {
+				slog.WarnContext(request.Context(), "eventsHandler - Error on Watch", "error", err)
+			}
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEvents serves a REST server whose event stream is written by stream.
+func fakeEvents(t *testing.T, stream func(w http.ResponseWriter, r *http.Request)) {
+	remote := httptest.NewTLSServer(http.HandlerFunc(stream))
+	t.Cleanup(remote.Close)
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+}
+
+func TestEventsRelayed(t *testing.T) {
+	fakeEvents(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/events" || r.Header.Get("Last-Event-ID") != "4" {
+			t.Errorf("Unexpected request %s %s", r.URL.Path, r.Header.Get("Last-Event-ID"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 5\nevent: server.deleted\ndata: {\"id\":7}\n\n")
+	})
+
+	req := editorRequest(t, "GET", "/events", url.Values{})
+	req.Header.Set("Last-Event-ID", "4")
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if rr.Header().Get("Content-Type") != "text/event-stream" ||
+		!strings.Contains(body, "id: 5\nevent: server.deleted\ndata: {\"id\":7}\n\n") {
+		t.Errorf("Expected the event to be relayed. Got %v %s", rr.Header(), body)
+	}
+}
+
+func TestEventsEndWithSession(t *testing.T) {
+	fakeEvents(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	keepAliveInterval = 10 * time.Millisecond
+	t.Cleanup(func() { keepAliveInterval = 15 * time.Second })
+
+	req := editorRequest(t, "GET", "/events", url.Values{})
+	handled := make(chan struct{})
+	go func() {
+		newRouter().ServeHTTP(httptest.NewRecorder(), req)
+		close(handled)
+	}()
+
+	select {
+	case <-handled:
+		t.Fatal("Expected the stream to last while the session does")
+	case <-time.After(50 * time.Millisecond):
+	}
+	c, _ := req.Cookie(sessionCookieName)
+	sessions.destroy(c.Value)
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the stream to end with the session")
+	}
+}
+
+func TestEventsEndOnShutdown(t *testing.T) {
+	fakeEvents(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	streams, endStreams := context.WithCancel(context.Background())
+	req := editorRequest(t, "GET", "/events", url.Values{})
+	req = req.WithContext(withStreams(req.Context(), streams))
+	handled := make(chan struct{})
+	go func() {
+		newRouter().ServeHTTP(httptest.NewRecorder(), req)
+		close(handled)
+	}()
+
+	endStreams()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the stream to end on shutdown")
+	}
+}
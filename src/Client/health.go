@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// How long a readiness check may take
+const readyTimeout = 2 * time.Second
+
+func writeStatus(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(response)
+}
+
+// healthHandler reports that the web client is alive.
+func healthHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	writeStatus(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyHandler reports whether the web client can handle requests, which
+// requires the REST server to be reachable.
+func readyHandler(w http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	ctx, cancel := context.WithTimeout(request.Context(), readyTimeout)
+	defer cancel()
+
+	checks := map[string]string{"rest_server": "ok"}
+	if err := api(request).Health(ctx); err != nil {
+		// Logged rather than shown, as anyone may ask
+		slog.ErrorContext(request.Context(), "readyHandler - Error on Health", "error", err)
+		checks["rest_server"] = "unavailable"
+		writeStatus(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "unavailable", "checks": checks})
+		return
+	}
+	writeStatus(w, http.StatusOK, map[string]interface{}{"status": "ready", "checks": checks})
+}
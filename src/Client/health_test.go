@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReadiness(t *testing.T) {
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			http.NotFound(w, r)
+		}
+	}))
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+	router := newRouter()
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s - Expected response code %d. Got %d", path, http.StatusOK, rr.Code)
+		}
+	}
+
+	remote.Close()
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected response code %d with the REST server down. Got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, `"rest_server":"unavailable"`) {
+		t.Errorf("Expected only 'unavailable' for the REST server. Got %s", body)
+	}
+}
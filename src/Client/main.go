@@ -1,17 +1,25 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
-	"encoding/json"
-	"html/template"
-	"io/ioutil"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
+	"admin-server/identity"
+	"admin-server/logging"
+	"admin-server/sadmin"
+	"admin-server/throttle"
+	"admin-server/tracing"
+
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -25,7 +33,7 @@ var timeout = time.Duration(time.Duration(httpTimeout) * time.Second)
 //      and for efficiency should only be created once and re-used."
 var client = &http.Client{
 	Timeout:   timeout,
-	Transport: tr,
+	Transport: traceTransport(instrumentTransport(tr)),
 }
 var tr = &http.Transport{
 	// Disable certificate check, effectively dropping down to SSL
@@ -34,213 +42,371 @@ var tr = &http.Transport{
 
 // ---------------------------------------
 
-type server struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+// Identity tokens only need to outlive a single REST call
+const identityTTL = time.Minute
+
+// api returns a client for the REST server acting on behalf of the request to
+// the web client: carrying its request ID and the signed-in user's identity.
+func api(request *http.Request) *sadmin.Client {
+	c := sadmin.New("https://"+conf.Remote.Host+":"+conf.Remote.Port, client)
+	c.User, c.Password = conf.Remote.User, conf.Remote.Password
+	c.Prepare = func(req *http.Request) {
+		if id := logging.RequestID(req.Context()); id != "" {
+			req.Header.Set(logging.Header, id)
+		}
+		if s := sessionFromRequest(request); s != nil && conf.IdentitySigningKey != "" {
+			if token, err := identity.Sign([]byte(conf.IdentitySigningKey), s.User, s.Role, identityTTL); err == nil {
+				req.Header.Set(identity.Header, token)
+			}
+		}
+	}
+	return c
 }
 
-var pageTemplates = template.Must(template.ParseGlob("../../templates/*.gohtml"))
-
-var remoteHost string
-var remotePort string
-var remoteAuthUser string
-var remoteAuthPass string
-
-var authUser string
-var authPass string
+type detailPageVars struct {
+	Server    sadmin.Server
+	NotFound  bool
+	Problem   *sadmin.Error
+	CSRFToken string
+}
 
-func listServersHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+func showServerHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
-	req, err := http.NewRequest("GET", "https://"+remoteHost+":"+remotePort+"/v1/servers", nil)
+	page := detailPageVars{CSRFToken: csrfToken(r)}
+	id, err := strconv.ParseInt(ps.ByName("id"), 10, 64)
 	if err != nil {
-		log.Printf("listServersHandler - Error on http.NewRequest: '%s'", err)
+		page.NotFound = true
+		renderStatus(w, r, http.StatusNotFound, "serverDetail.gohtml", page)
 		return
 	}
 
-	resp, err := client.Do(req)
+	page.Server, err = api(r).Get(r.Context(), id)
+	if errors.Is(err, sadmin.ErrNotFound) {
+		page.NotFound = true
+		renderStatus(w, r, http.StatusNotFound, "serverDetail.gohtml", page)
+		return
+	}
 	if err != nil {
-		log.Printf("listServersHandler - Error on request: '%v'", err)
+		slog.ErrorContext(r.Context(), "showServerHandler - Error on Get", "error", err)
+		page.Problem = problemFor(err)
+		renderProblem(w, r, page.Problem, "serverDetail.gohtml", page)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	render(w, r, "serverDetail.gohtml", page)
+}
+
+func main() {
+	args := os.Args[1:]
+
+	printConfig := len(args) > 1 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
+
+	cfg, err := loadConfig("admin_client", args)
 	if err != nil {
-		log.Printf("listServersHandler - Error on reading: '%v'", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		cfg.print(os.Stdout)
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
 		return
 	}
+	conf = *cfg
 
-	var serverList []server
-	err = json.Unmarshal(body, &serverList)
-	if err != nil {
-		log.Printf("listServersHandler - Unmarshal error: '%v'", err)
+	if err := logging.Setup(os.Stderr, conf.Log.Level, conf.Log.Format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	log.Println("Listed Server Entries", resp.StatusCode)
+	if err := loadTemplates(conf.Templates); err != nil {
+		slog.Error("Error loading templates", "error", err)
+		os.Exit(1)
+	}
 
-	pageTemplates.ExecuteTemplate(w, "serverList.gohtml", serverList)
-}
+	if conf.IdentitySigningKey == "" {
+		slog.Warn("IDENTITY_SIGNING_KEY is not set, changes will be attributed to the API user", "api_user", conf.Remote.User)
+	}
 
-func basicAuth(h httprouter.Handle, requiredUser, requiredPassword string) httprouter.Handle {
+	sessions = newSessionStore(conf.Session.IdleTimeout, conf.Session.AbsoluteTimeout)
+
+	if conf.OIDC.IssuerURL != "" {
+		oidcAuth, err = newOIDCAuthenticator(context.Background(),
+			conf.OIDC.IssuerURL,
+			conf.OIDC.ClientID,
+			conf.OIDC.ClientSecret,
+			conf.OIDC.RedirectURL,
+			conf.OIDC.GroupsClaim,
+			conf.OIDC.RoleMap)
+		if err != nil {
+			slog.Error("Error configuring single sign-on", "error", err)
+			os.Exit(1)
+		}
+	}
 
-	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
-		// Get the Basic Authentication credentials
-		user, password, hasAuth := req.BasicAuth()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		if hasAuth && user == requiredUser && password == requiredPassword {
-			// Delegate request to the given handle
-			h(w, req, ps)
-		} else {
-			// Request Basic Authentication otherwise
-			w.Header().Set("WWW-Authenticate", "Basic realm=Restricted")
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
-		}
+	shutdownTracing, err := tracing.Setup(ctx, "sadmin-client", conf.Tracing.Endpoint)
+	if err != nil {
+		slog.Error("Error configuring tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	ln, err := net.Listen("tcp", ":"+conf.Port)
+	if err != nil {
+		slog.Error("Error on listen", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Now serving servers ...", "port", conf.Port)
+	if err := serve(ctx, newServer(newRouter()), ln); err != nil {
+		slog.Error("Error on serve", "error", err)
+		os.Exit(1)
 	}
+	slog.Info("Shut down cleanly")
 }
 
-func main() {
+// newServer returns a server for the handler, with the configured timeouts.
+func newServer(h http.Handler) *http.Server {
+	// Event streams end on shutdown, which would otherwise wait for them
+	streams, endStreams := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Handler:      h,
+		ReadTimeout:  conf.Timeouts.Read,
+		WriteTimeout: conf.Timeouts.Write,
+		IdleTimeout:  conf.Timeouts.Idle,
+		BaseContext: func(net.Listener) context.Context {
+			return withStreams(context.Background(), streams)
+		},
+	}
+	srv.RegisterOnShutdown(endStreams)
+	return srv
+}
+
+// serve serves TLS on the listener until ctx is cancelled, then waits for
+// in-flight requests to finish, for up to the shutdown grace period.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ServeTLS(ln, conf.TLS.Cert, conf.TLS.Key)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
 
-	remoteHost = os.Getenv("REMOTE_HOST")
-	remotePort = os.Getenv("REMOTE_PORT")
-	remoteAuthUser = os.Getenv("REMOTE_AUTH_USER")
-	remoteAuthPass = os.Getenv("REMOTE_AUTH_PASSWORD")
+	slog.Info("Shutting down ...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.Timeouts.ShutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("requests still in progress after %v: %v", conf.Timeouts.ShutdownGrace, err)
+	}
+	return nil
+}
 
-	port := os.Getenv("PORT")
+func newRouter() *httprouter.Router {
+	return newRouterWith(throttle.New(requestRate, requestBurst))
+}
 
-	authUser = os.Getenv("AUTH_USER")
-	authPass = os.Getenv("AUTH_PASSWORD")
+// newRouterWith routes requests, throttling them with th.
+func newRouterWith(th *throttle.Throttle) *httprouter.Router {
 
 	router := httprouter.New()
 
 	// handle static assets (not logged)
-	router.ServeFiles("/static/*filepath", http.Dir("../../assets"))
+	router.ServeFiles("/static/*filepath", assets(conf.Assets))
+
+	router.Handler("GET", "/metrics", metricsHandler)
+	r := routes{router}
+
+	r.GET("/healthz", healthHandler)
+	r.GET("/readyz", readyHandler)
 
-	router.GET("/Servers", basicAuth(listServersHandler, authUser, authPass))
-	router.GET("/createServer", basicAuth(showCreateServerForm, authUser, authPass))
-	router.POST("/createServer", basicAuth(createServerEntry, authUser, authPass))
-	router.GET("/deleteServer", basicAuth(showDeleteServerForm, authUser, authPass))
-	router.POST("/deleteServer", basicAuth(deleteServerEntry, authUser, authPass))
+	r.GET("/login", showLoginForm)
+	r.POST("/login", login(th))
+	r.POST("/logout", requireSession(th, logout))
+	r.POST("/theme", setTheme)
+	if oidcAuth != nil {
+		r.GET("/oidc/login", oidcAuth.startLogin)
+		r.GET("/oidc/callback", oidcAuth.callback)
+	}
 
-	log.Println("Now serving servers ...")
-	log.Fatal(http.ListenAndServeTLS(":"+port, "../../certificates/WEB-server.pem", "../../certificates/WEB-server-private-key.pem", router))
+	r.GET("/Servers", requireRole(th, identity.RoleViewer, listServersHandler))
+	r.GET("/servers/:id", requireRole(th, identity.RoleViewer, showServerHandler))
+	r.GET("/createServer", requireRole(th, identity.RoleEditor, showCreateServerForm))
+	r.POST("/createServer", requireRole(th, identity.RoleEditor, createServerEntry))
+	r.GET("/editServer", requireRole(th, identity.RoleEditor, showEditServerForm))
+	r.POST("/editServer", requireRole(th, identity.RoleEditor, editServerEntry))
+	r.GET("/deleteServer", requireRole(th, identity.RoleAdmin, showDeleteServerForm))
+	r.POST("/deleteServer", requireRole(th, identity.RoleAdmin, deleteServerEntry))
+	r.GET("/bulk", requireRole(th, identity.RoleEditor, showBulkForm))
+	r.POST("/bulk", requireRole(th, identity.RoleEditor, bulkAction))
+	r.GET("/events", requireRole(th, identity.RoleViewer, eventsHandler))
+
+	return router
 }
 
 type createPageVars struct {
-	Name        string
-	Invalid     bool
-	Duplicate   bool
-	Error       bool
-	ErrorString string
+	Name      string
+	Invalid   bool
+	Duplicate bool
+	Problem   *sadmin.Error
+	CSRFToken string
 }
 
 func showCreateServerForm(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
-	page := createPageVars{Name: ""} // "required"}
-	pageTemplates.ExecuteTemplate(writer, "createServer.gohtml", page)
+	page := createPageVars{Name: "", CSRFToken: csrfToken(request)} // "required"}
+	render(writer, request, "createServer.gohtml", page)
 }
 
 func createServerEntry(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
 
-	page := createPageVars{request.FormValue("name"), false, false, false, ""}
+	page := createPageVars{request.FormValue("name"), false, false, nil, csrfToken(request)}
 
 	// Check for valid server name
 	if !serverNameValid(request.FormValue("name")) {
 		page.Invalid = true
-		pageTemplates.ExecuteTemplate(writer, "createServer.gohtml", page)
+		render(writer, request, "createServer.gohtml", page)
 		return
 	}
 
-	payload := []byte(`{"name":"` + request.FormValue("name") + `"}`)
+	s, err := api(request).Create(request.Context(), request.FormValue("name"))
 
-	req, err := http.NewRequest("POST", "https://"+remoteHost+":"+remotePort+"/v1/servers", bytes.NewBuffer(payload))
-	if err != nil {
-		log.Printf("createServerEntry - Error on http.NewRequest: %s", err)
+	// Check for duplicate
+	if errors.Is(err, sadmin.ErrConflict) {
+		page.Duplicate = true
+		render(writer, request, "createServer.gohtml", page)
 		return
 	}
-	req.SetBasicAuth(remoteAuthUser, remoteAuthPass)
 
-	resp, err := client.Do(req)
+	// Check for errors
 	if err != nil {
-		log.Printf("createServerEntry - Error on request: '%v'", err)
+		slog.ErrorContext(request.Context(), "createServerEntry - Error on Create", "error", err)
+		page.Problem = problemFor(err)
+		renderProblem(writer, request, page.Problem, "createServer.gohtml", page)
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	slog.InfoContext(request.Context(), "Created Server Entry", "id", s.ID, "name", s.Name)
+
+	redirectWithFlash(writer, request, "/Servers", flash{flashSuccess, "Created " + s.Name})
+}
+
+type editPageVars struct {
+	ID             int64
+	Name           string
+	Invalid        bool
+	Duplicate      bool
+	NoLongerExists bool
+	Problem        *sadmin.Error
+	CSRFToken      string
+}
+
+func showEditServerForm(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+	id, _ := strconv.ParseInt(request.FormValue("id"), 10, 64)
+	page := editPageVars{ID: id, CSRFToken: csrfToken(request)}
+
+	// Pre-fill the current values
+	s, err := api(request).Get(request.Context(), id)
 	if err != nil {
-		log.Printf("createServerEntry - Error on reading: '%v'", err)
+		slog.ErrorContext(request.Context(), "showEditServerForm - Error on Get", "error", err)
+		page.NoLongerExists = errors.Is(err, sadmin.ErrNotFound)
+		if !page.NoLongerExists {
+			page.Problem = problemFor(err)
+			renderProblem(writer, request, page.Problem, "editServer.gohtml", page)
+			return
+		}
+		render(writer, request, "editServer.gohtml", page)
 		return
 	}
+	page.Name = s.Name
+	render(writer, request, "editServer.gohtml", page)
+}
 
-	// Check for duplicate
-	if resp.StatusCode == http.StatusConflict {
+func editServerEntry(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+
+	id, _ := strconv.ParseInt(request.FormValue("id"), 10, 64)
+	page := editPageVars{ID: id, Name: request.FormValue("name"), CSRFToken: csrfToken(request)}
+
+	// Check for valid server name
+	if !serverNameValid(request.FormValue("name")) {
+		page.Invalid = true
+		render(writer, request, "editServer.gohtml", page)
+		return
+	}
+
+	s, err := api(request).Update(request.Context(), id, request.FormValue("name"))
+
+	// Check for duplicate, or the server having been deleted meanwhile
+	if errors.Is(err, sadmin.ErrConflict) {
 		page.Duplicate = true
-		pageTemplates.ExecuteTemplate(writer, "createServer.gohtml", page)
+		render(writer, request, "editServer.gohtml", page)
+		return
+	}
+	if errors.Is(err, sadmin.ErrNotFound) {
+		page.NoLongerExists = true
+		render(writer, request, "editServer.gohtml", page)
 		return
 	}
 
 	// Check for errors
-	if resp.StatusCode != http.StatusCreated {
-		page.Error = true
-		page.ErrorString = string(body)
-		pageTemplates.ExecuteTemplate(writer, "createServer.gohtml", page)
+	if err != nil {
+		slog.ErrorContext(request.Context(), "editServerEntry - Error on Update", "error", err)
+		page.Problem = problemFor(err)
+		renderProblem(writer, request, page.Problem, "editServer.gohtml", page)
 		return
 	}
 
-	log.Println("Created Server Entry", resp.StatusCode, request.FormValue("name"))
+	slog.InfoContext(request.Context(), "Modified Server Entry", "id", s.ID, "name", s.Name)
 
-	// redisplay servers list
-	listServersHandler(writer, request, ps)
+	redirectWithFlash(writer, request, "/Servers", flash{flashSuccess, "Saved " + s.Name})
 }
 
 type deletePageVars struct {
-	ID             int
+	ID             int64
 	Name           string
 	NoLongerExists bool
-	Error          bool
-	ErrorString    string
+	Problem        *sadmin.Error
+	CSRFToken      string
 }
 
 func showDeleteServerForm(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
-	id, _ := strconv.Atoi(request.FormValue("id"))
-	page := deletePageVars{ID: id, Name: request.FormValue("name")}
-	pageTemplates.ExecuteTemplate(writer, "deleteServer.gohtml", page)
+	id, _ := strconv.ParseInt(request.FormValue("id"), 10, 64)
+	page := deletePageVars{ID: id, Name: request.FormValue("name"), CSRFToken: csrfToken(request)}
+	render(writer, request, "deleteServer.gohtml", page)
 }
 
 func deleteServerEntry(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
 
-	id, _ := strconv.Atoi(request.FormValue("id"))
-	page := deletePageVars{ID: id, Name: request.FormValue("name")}
-
-	req, err := http.NewRequest("DELETE", "https://"+remoteHost+":"+remotePort+"/v1/servers/"+request.FormValue("id"), nil)
-	if err != nil {
-		log.Printf("deleteServerEntry - Error on http.NewRequest: %s", err)
-		return
-	}
-	req.SetBasicAuth(remoteAuthUser, remoteAuthPass)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("deleteServerEntry - Error on request: '%v'", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("deleteServerEntry - Error on reading: '%v'", err)
-		return
-	}
+	id, _ := strconv.ParseInt(request.FormValue("id"), 10, 64)
+	page := deletePageVars{ID: id, Name: request.FormValue("name"), CSRFToken: csrfToken(request)}
 
 	// Check for errors
-	if resp.StatusCode != http.StatusOK {
-		page.Error = true
-		page.ErrorString = string(body)
-		pageTemplates.ExecuteTemplate(writer, "deleteServer.gohtml", page)
+	if err := api(request).Delete(request.Context(), id); err != nil {
+		slog.ErrorContext(request.Context(), "deleteServerEntry - Error on Delete", "error", err)
+		page.NoLongerExists = errors.Is(err, sadmin.ErrNotFound)
+		if !page.NoLongerExists {
+			page.Problem = problemFor(err)
+			renderProblem(writer, request, page.Problem, "deleteServer.gohtml", page)
+			return
+		}
+		render(writer, request, "deleteServer.gohtml", page)
 		return
 	}
 
-	log.Println("Deleted Server Entry", resp.StatusCode, request.FormValue("name"))
+	slog.InfoContext(request.Context(), "Deleted Server Entry", "id", id, "name", request.FormValue("name"))
 
-	// redisplay servers list
-	listServersHandler(writer, request, ps)
+	redirectWithFlash(writer, request, "/Servers", flash{flashSuccess, "Deleted " + page.Name})
 }
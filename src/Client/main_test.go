@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"admin-server/identity"
+	"admin-server/logging"
+	"admin-server/sadmin"
+)
+
+func TestMain(m *testing.M) {
+	if err := loadTemplates(conf.Templates); err != nil {
+		log.Fatalf("Error loading templates: '%v'", err)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeRemote serves a REST server with the named servers, by ID, in place of
+// the configured one.
+func fakeRemote(t *testing.T, names map[string]string) {
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/servers" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Total-Count", "0")
+			w.Write([]byte(`[]`))
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/v1/servers/")
+		if _, ok := names[id]; !ok {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title":"Not Found","status":404,"code":"not_found","detail":"Server not found"}`))
+			return
+		}
+		name := names[id]
+		if r.Method == "PUT" {
+			var s sadmin.Server
+			json.NewDecoder(r.Body).Decode(&s)
+			for other, n := range names {
+				if other != id && n == s.Name {
+					w.Header().Set("Content-Type", "application/problem+json")
+					w.WriteHeader(http.StatusConflict)
+					w.Write([]byte(`{"title":"Conflict","status":409,"code":"duplicate_name"}`))
+					return
+				}
+			}
+			name = s.Name
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":` + id + `,"name":"` + name + `",` +
+			`"created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-02T14:05:00Z"}`))
+	}))
+	t.Cleanup(remote.Close)
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+}
+
+// editorRequest returns a request from a signed-in editor.
+func editorRequest(t *testing.T, method, target string, form url.Values) *http.Request {
+	s := sessions.create("editor", identity.RoleEditor)
+	t.Cleanup(func() { sessions.destroy(s.ID) })
+
+	form.Set(csrfFieldName, s.CSRFToken)
+	var req *http.Request
+	if method == "GET" {
+		req = httptest.NewRequest(method, target+"?"+form.Encode(), nil)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: s.ID})
+	return req
+}
+
+func TestEditServerForm(t *testing.T) {
+	fakeRemote(t, map[string]string{"7": "web-01.example.com"})
+
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, editorRequest(t, "GET", "/editServer", url.Values{"id": {"7"}}))
+
+	body := rr.Body.String()
+	if !strings.Contains(body, `value="web-01.example.com"`) || !strings.Contains(body, `name="id" value="7"`) {
+		t.Errorf("Expected the form to be pre-filled. Got %s", body)
+	}
+}
+
+func TestEditServer(t *testing.T) {
+	fakeRemote(t, map[string]string{"7": "web-01.example.com", "8": "web-02.example.com"})
+
+	for _, tc := range []struct {
+		id, name, expected string
+	}{
+		{"7", "web-03", "Invalid server name!"},
+		{"7", "web-02.example.com", "Another server already has this name!"},
+		{"9", "web-03.example.com", "This server no longer exists!"},
+	} {
+		rr := httptest.NewRecorder()
+		form := url.Values{"id": {tc.id}, "name": {tc.name}}
+		newRouter().ServeHTTP(rr, editorRequest(t, "POST", "/editServer", form))
+
+		if body := rr.Body.String(); !strings.Contains(body, tc.expected) {
+			t.Errorf("Editing %s to '%s' - Expected '%s'. Got %s", tc.id, tc.name, tc.expected, body)
+		}
+	}
+}
+
+func TestEditServerRedirects(t *testing.T) {
+	fakeRemote(t, map[string]string{"7": "web-01.example.com"})
+
+	req := editorRequest(t, "POST", "/editServer", url.Values{"id": {"7"}, "name": {"web-03.example.com"}})
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/Servers" {
+		t.Fatalf("Expected a redirect to the server list. Got %d %v", rr.Code, rr.Header())
+	}
+
+	// The message is shown once, on the next page
+	for _, want := range []bool{true, false} {
+		list := httptest.NewRequest("GET", "/Servers", nil)
+		list.AddCookie(req.Cookies()[0])
+		rr = httptest.NewRecorder()
+		newRouter().ServeHTTP(rr, list)
+		if got := strings.Contains(rr.Body.String(), "Saved web-03.example.com"); got != want {
+			t.Errorf("Expected the message shown %v. Got %s", want, rr.Body.String())
+		}
+	}
+}
+
+func TestEditDeletedServerForm(t *testing.T) {
+	fakeRemote(t, map[string]string{})
+
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, editorRequest(t, "GET", "/editServer", url.Values{"id": {"7"}}))
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "This server no longer exists!") || strings.Contains(body, `type="submit" value="Save"`) {
+		t.Errorf("Expected no form for a deleted server. Got %s", body)
+	}
+}
+
+func TestServerDetail(t *testing.T) {
+	fakeRemote(t, map[string]string{"7": "web-01.example.com"})
+
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, editorRequest(t, "GET", "/servers/7", url.Values{}))
+
+	body := rr.Body.String()
+	for _, expected := range []string{"web-01.example.com", "2024-05-01 09:30:00 UTC", "2024-05-02 14:05:00 UTC",
+		`action="/editServer"`, `action="/deleteServer"`} {
+		if rr.Code != http.StatusOK || !strings.Contains(body, expected) {
+			t.Errorf("Expected '%s'. Got %d %s", expected, rr.Code, body)
+		}
+	}
+}
+
+func TestServerDetailNotFound(t *testing.T) {
+	fakeRemote(t, map[string]string{})
+
+	for _, path := range []string{"/servers/7", "/servers/x"} {
+		rr := httptest.NewRecorder()
+		newRouter().ServeHTTP(rr, editorRequest(t, "GET", path, url.Values{}))
+
+		if body := rr.Body.String(); rr.Code != http.StatusNotFound || !strings.Contains(body, "no longer exists!") {
+			t.Errorf("%s - Expected a not found page. Got %d %s", path, rr.Code, body)
+		}
+	}
+}
+
+func TestAPICarriesIdentity(t *testing.T) {
+	var token string
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get(identity.Header)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":7,"name":"web-01.example.com"}`))
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+	defer func(key string) { conf.IdentitySigningKey = key }(conf.IdentitySigningKey)
+	conf.IdentitySigningKey = "test identity signing key"
+
+	req := editorRequest(t, "GET", "/servers/7", url.Values{})
+	req = req.WithContext(context.WithValue(req.Context(), sessionContextKey, sessions.get(req.Cookies()[0].Value)))
+	if _, err := api(req).Get(req.Context(), 7); err != nil {
+		t.Fatalf("Error on Get: %v", err)
+	}
+
+	// The REST server verifies what the web client signs
+	id, err := identity.Verify([]byte(conf.IdentitySigningKey), token, time.Now())
+	if err != nil || id.User != "editor" || id.Role != identity.RoleEditor {
+		t.Errorf("Expected the editor's identity. Got %+v, %v", id, err)
+	}
+}
+
+func TestRequestIDForwarded(t *testing.T) {
+	var forwarded string
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(logging.Header)
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+	router := newRouter()
+
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	req.Header.Set(logging.Header, "from-proxy")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if id := rr.Header().Get(logging.Header); id != "from-proxy" || forwarded != "from-proxy" {
+		t.Errorf("Expected request ID to be returned and forwarded. Got '%s' and '%s'", id, forwarded)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if id := rr.Header().Get(logging.Header); !logging.ValidRequestID(id) || forwarded != id {
+		t.Errorf("Expected a new request ID to be returned and forwarded. Got '%s' and '%s'", id, forwarded)
+	}
+}
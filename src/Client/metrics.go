@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"admin-server/middleware"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The Prometheus metrics of the web client, served on /metrics.
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sadmin_http_requests_total",
+		Help: "HTTP requests handled, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sadmin_http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests, by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	upstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sadmin_upstream_requests_total",
+		Help: "Requests made to the REST server, by method and status code.",
+	}, []string{"method", "code"})
+
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sadmin_upstream_request_duration_seconds",
+		Help:    "Time taken by requests to the REST server, by method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	upstreamInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sadmin_upstream_in_flight_requests",
+		Help: "Requests to the REST server in progress.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		requestsTotal,
+		requestDuration,
+		upstreamRequests,
+		upstreamDuration,
+		upstreamInFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// metricsHandler serves the metrics.
+var metricsHandler = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+
+// instrumentTransport counts and times requests to the REST server.
+func instrumentTransport(rt http.RoundTripper) http.RoundTripper {
+	return promhttp.InstrumentRoundTripperInFlight(upstreamInFlight,
+		promhttp.InstrumentRoundTripperCounter(upstreamRequests,
+			promhttp.InstrumentRoundTripperDuration(upstreamDuration, rt)))
+}
+
+// observe records the metrics of a request to the web client.
+func observe(method, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	requestsTotal.WithLabelValues(route, method, code).Inc()
+	requestDuration.WithLabelValues(route, method, code).Observe(elapsed.Seconds())
+}
+
+// routes registers tracked handlers on the router.
+type routes struct {
+	router *httprouter.Router
+}
+
+func (r routes) GET(path string, h httprouter.Handle) {
+	r.router.GET(path, middleware.Track("GET", path, observe, h))
+}
+func (r routes) POST(path string, h httprouter.Handle) {
+	r.router.POST(path, middleware.Track("POST", path, observe, h))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+	router := newRouter()
+
+	for _, path := range []string{"/readyz", "/Servers"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected response code %d. Got %d", http.StatusOK, rr.Code)
+	}
+
+	body := rr.Body.String()
+	for _, expected := range []string{
+		`sadmin_http_requests_total{code="200",method="GET",route="/readyz"}`,
+		`sadmin_http_requests_total{code="303",method="GET",route="/Servers"}`,
+		`sadmin_upstream_requests_total{code="200",method="get"}`,
+		`sadmin_upstream_request_duration_seconds_count{code="200",method="get"}`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metric '%s'", expected)
+		}
+	}
+}
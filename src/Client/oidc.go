@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"admin-server/identity"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/oauth2"
+)
+
+const oidcStateCookieName = "sadmin_oidc_state"
+
+// How long a user has to complete sign-in at the identity provider
+const oidcLoginTimeout = 10 * time.Minute
+
+// oidcAuthenticator signs users in via an OpenID Connect identity provider
+// using the authorization code flow with PKCE.
+type oidcAuthenticator struct {
+	verifier    *oidc.IDTokenVerifier
+	config      oauth2.Config
+	httpClient  *http.Client
+	groupsClaim string
+	roleMap     map[string]string // group -> role
+
+	mu      sync.Mutex
+	pending map[string]pendingLogin // keyed by state
+}
+
+type pendingLogin struct {
+	nonce        string
+	codeVerifier string
+	expires      time.Time
+}
+
+// oidcAuth is nil unless single sign-on has been configured.
+var oidcAuth *oidcAuthenticator
+
+// parseRoleMap parses a role mapping such as "sadmin-admins=admin,sadmin-ops=editor".
+func parseRoleMap(s string) (map[string]string, error) {
+	roleMap := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid role mapping '%s'", entry)
+		}
+		role := strings.TrimSpace(kv[1])
+		if !identity.ValidRole(role) {
+			return nil, fmt.Errorf("unknown role '%s' for group '%s'", role, kv[0])
+		}
+		roleMap[strings.TrimSpace(kv[0])] = role
+	}
+	return roleMap, nil
+}
+
+func newOIDCAuthenticator(ctx context.Context, issuer, clientID, clientSecret, redirectURL, groupsClaim, roleMapping string) (*oidcAuthenticator, error) {
+
+	roleMap, err := parseRoleMap(roleMapping)
+	if err != nil {
+		return nil, err
+	}
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	// The identity provider is expected to have a valid certificate
+	httpClient := &http.Client{Timeout: timeout}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, httpClient), issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcAuthenticator{
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+		},
+		httpClient:  httpClient,
+		groupsClaim: groupsClaim,
+		roleMap:     roleMap,
+		pending:     map[string]pendingLogin{},
+	}, nil
+}
+
+// roleForGroups returns the highest role granted by any of the groups.
+func (a *oidcAuthenticator) roleForGroups(groups []string) string {
+	role := ""
+	for _, g := range groups {
+		if r, ok := a.roleMap[g]; ok && !(identity.Identity{Role: role}).HasRole(r) {
+			role = r
+		}
+	}
+	return role
+}
+
+func (a *oidcAuthenticator) startLogin(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+
+	state := randomToken()
+	login := pendingLogin{
+		nonce:        randomToken(),
+		codeVerifier: oauth2.GenerateVerifier(),
+		expires:      time.Now().Add(oidcLoginTimeout),
+	}
+
+	a.mu.Lock()
+	for s, p := range a.pending {
+		if time.Now().After(p.expires) {
+			delete(a.pending, s)
+		}
+	}
+	a.pending[state] = login
+	a.mu.Unlock()
+
+	// Bind the state to this browser
+	setCookie(writer, oidcStateCookieName, state, int(oidcLoginTimeout.Seconds()))
+
+	url := a.config.AuthCodeURL(state, oidc.Nonce(login.nonce), oauth2.S256ChallengeOption(login.codeVerifier))
+	http.Redirect(writer, request, url, http.StatusFound)
+}
+
+// takePending removes and returns the pending login for the state.
+func (a *oidcAuthenticator) takePending(state string) (pendingLogin, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	login, ok := a.pending[state]
+	delete(a.pending, state)
+	if ok && time.Now().After(login.expires) {
+		return login, false
+	}
+	return login, ok
+}
+
+type oidcClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// identify exchanges the authorization code and returns the verified user and role.
+func (a *oidcAuthenticator) identify(ctx context.Context, code string, login pendingLogin) (user, role string, err error) {
+
+	ctx = oidc.ClientContext(ctx, a.httpClient)
+
+	token, err := a.config.Exchange(ctx, code, oauth2.VerifierOption(login.codeVerifier))
+	if err != nil {
+		return "", "", err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", "", errors.New("no id_token in token response")
+	}
+	idToken, err := a.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", "", err
+	}
+	if idToken.Nonce != login.nonce {
+		return "", "", errors.New("nonce mismatch")
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return "", "", err
+	}
+	var all map[string]interface{}
+	if err := idToken.Claims(&all); err != nil {
+		return "", "", err
+	}
+	var groups []string
+	switch g := all[a.groupsClaim].(type) {
+	case []interface{}:
+		for _, v := range g {
+			if s, ok := v.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	case string:
+		groups = []string{g}
+	}
+
+	user = claims.PreferredUsername
+	if user == "" {
+		user = claims.Email
+	}
+	if user == "" {
+		user = claims.Subject
+	}
+
+	return user, a.roleForGroups(groups), nil
+}
+
+func (a *oidcAuthenticator) callback(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+
+	state := request.FormValue("state")
+	c, err := request.Cookie(oidcStateCookieName)
+	if err != nil || !tokensMatch(c.Value, state) {
+		slog.WarnContext(request.Context(), "oidcCallback - State mismatch")
+		showLoginError(writer, request, http.StatusBadRequest, "Sign-in could not be verified, please try again.")
+		return
+	}
+	setCookie(writer, oidcStateCookieName, "", -1)
+
+	login, ok := a.takePending(state)
+	if !ok {
+		slog.WarnContext(request.Context(), "oidcCallback - Unknown or expired state")
+		showLoginError(writer, request, http.StatusBadRequest, "Sign-in took too long, please try again.")
+		return
+	}
+
+	if e := request.FormValue("error"); e != "" {
+		slog.WarnContext(request.Context(), "oidcCallback - Identity provider error", "error", e, "description", request.FormValue("error_description"))
+		showLoginError(writer, request, http.StatusUnauthorized, "Sign-in was refused by the identity provider.")
+		return
+	}
+
+	user, role, err := a.identify(request.Context(), request.FormValue("code"), login)
+	if err != nil {
+		slog.WarnContext(request.Context(), "oidcCallback - Error on identify", "error", err)
+		showLoginError(writer, request, http.StatusUnauthorized, "Sign-in could not be verified, please try again.")
+		return
+	}
+	if role == "" {
+		slog.WarnContext(request.Context(), "oidcCallback - No role mapped", "user", user)
+		showLoginError(writer, request, http.StatusForbidden, "You are not authorized to use this application.")
+		return
+	}
+
+	s := sessions.create(user, role)
+	setCookie(writer, sessionCookieName, s.ID, 0)
+
+	slog.InfoContext(request.Context(), "Logged in via SSO", "user", user, "role", role)
+
+	http.Redirect(writer, request, "/Servers", http.StatusSeeOther)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"admin-server/identity"
+)
+
+// stubIdP is a minimal OpenID Connect provider for testing.
+type stubIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	user   string
+	groups []string
+
+	mu    sync.Mutex
+	codes map[string]url.Values // code -> authorization request
+}
+
+func newStubIdP(t *testing.T, user string, groups []string) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &stubIdP{key: key, user: user, groups: groups, codes: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		code := randomToken()
+		idp.mu.Lock()
+		idp.codes[code] = r.URL.Query()
+		idp.mu.Unlock()
+		redirect := r.FormValue("redirect_uri") + "?" + url.Values{"code": {code}, "state": {r.FormValue("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		authReq, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.mu.Unlock()
+
+		// Verify the PKCE code verifier against the challenge
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || authReq.Get("code_challenge_method") != "S256" ||
+			authReq.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     idp.idToken(t, authReq.Get("client_id"), authReq.Get("nonce")),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+func (idp *stubIdP) idToken(t *testing.T, audience, nonce string) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":                idp.URL,
+		"sub":                "1234",
+		"aud":                audience,
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"preferred_username": idp.user,
+		"groups":             idp.groups,
+	})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// ssoLogin runs the browser side of a single sign-on and returns the callback response.
+func ssoLogin(t *testing.T, idp *stubIdP) *httptest.ResponseRecorder {
+	var err error
+	oidcAuth, err = newOIDCAuthenticator(context.Background(), idp.URL, "sadmin", "client-secret",
+		"https://localhost:8200/oidc/callback", "", "sadmin-admins=admin, sadmin-ops=editor")
+	if err != nil {
+		t.Fatalf("Error on newOIDCAuthenticator: %v", err)
+	}
+	router := newRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/oidc/login", nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("Login - Expected response code %d. Got %d", http.StatusFound, rr.Code)
+	}
+	authURL, _ := url.Parse(rr.Header().Get("Location"))
+	if authURL.Query().Get("code_challenge_method") != "S256" {
+		t.Errorf("Expected a PKCE challenge. Got '%s'", authURL)
+	}
+	stateCookie := rr.Result().Cookies()[0]
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirect.Get(authURL.String())
+	if err != nil {
+		t.Fatalf("Error on authorize: %v", err)
+	}
+	resp.Body.Close()
+	callback, _ := url.Parse(resp.Header.Get("Location"))
+
+	req := httptest.NewRequest("GET", "/oidc/callback?"+callback.RawQuery, nil)
+	req.AddCookie(stateCookie)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSingleSignOn(t *testing.T) {
+	idp := newStubIdP(t, "jdoe", []string{"everyone", "sadmin-ops"})
+	defer idp.Close()
+	defer func() { oidcAuth = nil }()
+
+	rr := ssoLogin(t, idp)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Callback - Expected response code %d. Got %d", http.StatusSeeOther, rr.Code)
+	}
+	var s *session
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			s = sessions.get(c.Value)
+		}
+	}
+	if s == nil {
+		t.Fatal("Callback - No session created!")
+	}
+	if s.User != "jdoe" || s.Role != identity.RoleEditor {
+		t.Errorf("Expected 'jdoe' as editor. Got '%s' as '%s'", s.User, s.Role)
+	}
+}
+
+func TestSingleSignOnWithoutRole(t *testing.T) {
+	idp := newStubIdP(t, "guest", []string{"everyone"})
+	defer idp.Close()
+	defer func() { oidcAuth = nil }()
+
+	rr := ssoLogin(t, idp)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Callback - Expected response code %d. Got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestParseRoleMap(t *testing.T) {
+	if _, err := parseRoleMap("sadmin-admins=root"); err == nil {
+		t.Error("Unknown role accepted!")
+	}
+	if _, err := parseRoleMap("sadmin-admins"); err == nil {
+		t.Error("Missing role accepted!")
+	}
+	if _, err := parseRoleMap("sadmin-admins= "); err == nil {
+		t.Error("Empty role accepted!")
+	}
+	m, err := parseRoleMap("a=admin,b=viewer,")
+	if err != nil || len(m) != 2 || m["b"] != identity.RoleViewer {
+		t.Errorf("Expected 2 mappings. Got %v %v", m, err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"admin-server/sadmin"
+)
+
+// problemFor returns the problem to show the user for a failed call to the
+// REST server, including when it could not be reached at all. Only problems
+// with the user's request are passed on: anything else the REST server
+// refused, such as the web client's credentials, is a bad gateway as far as
+// the user is concerned, and being throttled means the service is unavailable.
+func problemFor(err error) *sadmin.Error {
+	var e *sadmin.Error
+	if errors.As(err, &e) {
+		switch e.Status {
+		case http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity:
+			return e
+		case http.StatusTooManyRequests:
+			return &sadmin.Error{
+				Title:     http.StatusText(http.StatusServiceUnavailable),
+				Status:    http.StatusServiceUnavailable,
+				Detail:    "The inventory is busy, please try again shortly.",
+				RequestID: e.RequestID,
+			}
+		}
+		if e.Status < http.StatusInternalServerError {
+			return &sadmin.Error{
+				Title:     http.StatusText(http.StatusBadGateway),
+				Status:    http.StatusBadGateway,
+				Detail:    "The inventory refused the request, please try again later.",
+				RequestID: e.RequestID,
+			}
+		}
+		p := *e
+		p.Title, p.Status = http.StatusText(http.StatusBadGateway), http.StatusBadGateway
+		if p.Detail == "" {
+			p.Detail = "The inventory could not complete the request, please try again."
+		}
+		return &p
+	}
+
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &ne) && ne.Timeout() {
+		return &sadmin.Error{
+			Title:  http.StatusText(http.StatusGatewayTimeout),
+			Status: http.StatusGatewayTimeout,
+			Detail: "The inventory did not respond in time, please try again.",
+		}
+	}
+	return &sadmin.Error{
+		Title:  http.StatusText(http.StatusBadGateway),
+		Status: http.StatusBadGateway,
+		Detail: "The inventory could not be reached, please try again.",
+	}
+}
+
+type errorPageVars struct {
+	Problem   *sadmin.Error
+	Retry     string
+	CSRFToken string
+}
+
+// renderProblem renders the page, which shows the problem, with the problem's
+// status. If the REST server failed or could not be reached, there is nothing
+// more the page can show, so the error page is rendered instead.
+func renderProblem(w http.ResponseWriter, r *http.Request, p *sadmin.Error, name string, page interface{}) {
+	if p.Status < http.StatusInternalServerError {
+		renderStatus(w, r, p.Status, name, page)
+		return
+	}
+	renderStatus(w, r, p.Status, "error.gohtml", errorPageVars{Problem: p, Retry: retryLink(r), CSRFToken: csrfToken(r)})
+}
+
+// retryLink returns where to try again: the same page, or for a form, the page
+// the form was on.
+func retryLink(r *http.Request) string {
+	if r.Method == "GET" {
+		return r.URL.RequestURI()
+	}
+	return backTo(r)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"admin-server/identity"
+	"admin-server/sadmin"
+)
+
+func TestProblemDisplayed(t *testing.T) {
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"title":"Internal Server Error","status":500,"code":"internal_error","detail":"The request could not be completed","request_id":"req-42"}`))
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+
+	s := sessions.create("auth_user", identity.RoleAdmin)
+	defer sessions.destroy(s.ID)
+
+	form := url.Values{"name": {"srv.example.com"}, csrfFieldName: {s.CSRFToken}}
+	req := httptest.NewRequest("POST", "/createServer", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", "https://"+req.Host+"/createServer")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: s.ID})
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "The request could not be completed") || !strings.Contains(body, "Reference: req-42") {
+		t.Errorf("Expected the problem to be displayed. Got %s", body)
+	}
+	if rr.Code != http.StatusBadGateway || !strings.Contains(body, `<a class="retry" href="/createServer">`) {
+		t.Errorf("Expected a bad gateway error page with a retry link. Got %d %s", rr.Code, body)
+	}
+	if strings.Contains(body, `"code"`) {
+		t.Errorf("Expected the problem not to be dumped. Got %s", body)
+	}
+}
+
+func TestUnreachableProblem(t *testing.T) {
+	p := problemFor(errors.New("dial tcp: connection refused"))
+	if p.Status != http.StatusBadGateway || strings.Contains(p.Detail, "dial") {
+		t.Errorf("Expected a problem without the cause. Got %+v", p)
+	}
+
+	p = problemFor(fmt.Errorf("Get: %w", context.DeadlineExceeded))
+	if p.Status != http.StatusGatewayTimeout {
+		t.Errorf("Expected a gateway timeout. Got %+v", p)
+	}
+
+	// Problems with the user's request are passed on
+	conflict := &sadmin.Error{Status: http.StatusConflict, Code: "duplicate_name"}
+	if p = problemFor(conflict); p != conflict {
+		t.Errorf("Expected the REST server's problem. Got %+v", p)
+	}
+
+	// But not the web client's own
+	for status, expected := range map[int]int{
+		http.StatusBadRequest:      http.StatusBadGateway,
+		http.StatusUnauthorized:    http.StatusBadGateway,
+		http.StatusForbidden:       http.StatusBadGateway,
+		http.StatusTooManyRequests: http.StatusServiceUnavailable,
+	} {
+		p = problemFor(&sadmin.Error{Status: status, Detail: "Invalid credentials", RequestID: "req-1"})
+		if p.Status != expected || p.RequestID != "req-1" || strings.Contains(p.Detail, "credentials") {
+			t.Errorf("%d - Expected %d with the request ID but not the detail. Got %+v", status, expected, p)
+		}
+	}
+	p = problemFor(&sadmin.Error{Status: http.StatusServiceUnavailable, RequestID: "req-1"})
+	if p.Status != http.StatusBadGateway || p.RequestID != "req-1" {
+		t.Errorf("Expected a bad gateway with the request ID. Got %+v", p)
+	}
+}
+
+func TestListErrorPage(t *testing.T) {
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`not json`))
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, editorRequest(t, "GET", "/Servers", url.Values{"q": {"web"}}))
+
+	body := rr.Body.String()
+	if rr.Code != http.StatusBadGateway || !strings.Contains(body, "The inventory is unavailable") ||
+		!strings.Contains(body, `href="/Servers?`) {
+		t.Errorf("Expected an error page with a retry link. Got %d %s", rr.Code, body)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"admin-server/sadmin"
+	"admin-server/validation"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Page sizes offered, the largest being the most the REST server returns at a time
+var pageSizes = []int{5, 10, 25}
+
+const defaultPageSize = 25
+
+// The furthest into the list a page may start, so that its offset is a valid one
+const maxStart = math.MaxInt32
+
+// Columns the server list may be sorted by, in either direction
+var sortColumns = []string{"name", "created_at", "updated_at"}
+
+const defaultSort = "name"
+
+// listPageVars are the server list, and the view of it: the search, order,
+// page size and page number, all of which are kept in the query string.
+type listPageVars struct {
+	Servers   []sadmin.Server
+	Total     int64
+	Search    string
+	Sort      string
+	Size      int
+	Page      int
+	Sizes     []int
+	Statuses  []string
+	Problem   *sadmin.Error
+	CSRFToken string
+}
+
+// listView reads the view of the server list from the query string,
+// replacing anything invalid with its default.
+func listView(query url.Values) listPageVars {
+	page := listPageVars{
+		Search: strings.TrimSpace(query.Get("q")),
+		Sort:   defaultSort,
+		Size:   defaultPageSize,
+		Page:   1,
+		Sizes:  pageSizes,
+		// For bulk actions
+		Statuses: validation.Statuses,
+	}
+	for _, column := range sortColumns {
+		if s := query.Get("sort"); s == column || s == "-"+column {
+			page.Sort = s
+		}
+	}
+	if size, _ := strconv.Atoi(query.Get("size")); size > 0 {
+		for _, allowed := range pageSizes {
+			if size == allowed {
+				page.Size = size
+			}
+		}
+	}
+	if n, _ := strconv.Atoi(query.Get("page")); n > 1 {
+		page.Page = n
+		if last := maxStart/page.Size + 1; n > last {
+			page.Page = last
+		}
+	}
+	return page
+}
+
+// link returns the address of the server list with the view changed.
+func (p listPageVars) link(sort string, page int) string {
+	query := url.Values{}
+	if p.Search != "" {
+		query.Set("q", p.Search)
+	}
+	if sort != defaultSort {
+		query.Set("sort", sort)
+	}
+	if p.Size != defaultPageSize {
+		query.Set("size", strconv.Itoa(p.Size))
+	}
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if len(query) == 0 {
+		return "/Servers"
+	}
+	return "/Servers?" + query.Encode()
+}
+
+// SortLink returns the address of the first page sorted by the column,
+// reversing the order if already sorted by it.
+func (p listPageVars) SortLink(column string) string {
+	if p.Sort == column {
+		return p.link("-"+column, 1)
+	}
+	return p.link(column, 1)
+}
+
+// SortMark shows whether, and in which direction, the list is sorted by the column.
+func (p listPageVars) SortMark(column string) string {
+	switch p.Sort {
+	case column:
+		return "▲"
+	case "-" + column:
+		return "▼"
+	}
+	return ""
+}
+
+// PrevLink returns the address of the previous page, if there is one.
+func (p listPageVars) PrevLink() string {
+	if p.Page <= 1 {
+		return ""
+	}
+	return p.link(p.Sort, p.Page-1)
+}
+
+// NextLink returns the address of the next page, if there is one.
+func (p listPageVars) NextLink() string {
+	if int64(p.Page*p.Size) >= p.Total {
+		return ""
+	}
+	return p.link(p.Sort, p.Page+1)
+}
+
+// First and Last number the servers on the page, from 1.
+func (p listPageVars) First() int64 {
+	return int64((p.Page-1)*p.Size + 1)
+}
+
+func (p listPageVars) Last() int64 {
+	return p.First() + int64(len(p.Servers)) - 1
+}
+
+// likePattern returns a SQL LIKE pattern for names containing the search.
+func likePattern(search string) string {
+	search = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search)
+	return "%" + search + "%"
+}
+
+func listServersHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	page := listView(r.URL.Query())
+	page.CSRFToken = csrfToken(r)
+
+	query := sadmin.Query{Sort: page.Sort, Start: (page.Page - 1) * page.Size, Count: page.Size}
+	if page.Search != "" {
+		query.Name = likePattern(page.Search)
+	}
+	result, err := api(r).Find(r.Context(), query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "listServersHandler - Error on Find", "error", err)
+		page.Problem = problemFor(err)
+		renderProblem(w, r, page.Problem, "serverList.gohtml", page)
+		return
+	}
+	page.Servers, page.Total = result.Servers, result.Total
+
+	slog.DebugContext(r.Context(), "Listed Server Entries", "count", len(page.Servers), "total", page.Total)
+
+	render(w, r, "serverList.gohtml", page)
+}
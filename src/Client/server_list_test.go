@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestListView(t *testing.T) {
+	for _, tc := range []struct {
+		query                string
+		search, sort         string
+		size, page           int
+		sortName, prev, next string
+	}{
+		{"", "", "name", 25, 1, "/Servers?sort=-name", "", "/Servers?page=2"},
+		{"q=web&sort=-updated_at&size=10&page=3", "web", "-updated_at", 10, 3,
+			"/Servers?q=web&size=10", "/Servers?page=2&q=web&size=10&sort=-updated_at", ""},
+		{"sort=id;DROP&size=1000&page=-1", "", "name", 25, 1, "/Servers?sort=-name", "", "/Servers?page=2"},
+		{"size=5&page=9223372036854775807", "", "name", 5, 429496730,
+			"/Servers?size=5&sort=-name", "/Servers?page=429496729&size=5", ""},
+	} {
+		query, _ := url.ParseQuery(tc.query)
+		p := listView(query)
+		p.Total = 30
+		if p.Search != tc.search || p.Sort != tc.sort || p.Size != tc.size || p.Page != tc.page {
+			t.Errorf("'%s' - Unexpected view %+v", tc.query, p)
+		}
+		if start := (p.Page - 1) * p.Size; start < 0 || start > maxStart {
+			t.Errorf("'%s' - Unexpected start %d", tc.query, start)
+		}
+		if link := p.SortLink("name"); link != tc.sortName {
+			t.Errorf("'%s' - Expected sort link '%s'. Got '%s'", tc.query, tc.sortName, link)
+		}
+		if link := p.PrevLink(); link != tc.prev {
+			t.Errorf("'%s' - Expected previous link '%s'. Got '%s'", tc.query, tc.prev, link)
+		}
+		if link := p.NextLink(); link != tc.next {
+			t.Errorf("'%s' - Expected next link '%s'. Got '%s'", tc.query, tc.next, link)
+		}
+	}
+}
+
+func TestLikePattern(t *testing.T) {
+	if p := likePattern(`web_1%`); p != `%web\_1\%%` {
+		t.Errorf("Expected wildcards to be escaped. Got '%s'", p)
+	}
+}
+
+func TestListServersSearch(t *testing.T) {
+	var form url.Values
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.Form
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Count", "12")
+		w.Write([]byte(`[{"id":7,"name":"web-01.example.com","created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-02T14:05:00Z"}]`))
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+
+	rr := httptest.NewRecorder()
+	req := editorRequest(t, "GET", "/Servers", url.Values{"q": {"web"}, "sort": {"-created_at"}, "size": {"5"}, "page": {"2"}})
+	newRouter().ServeHTTP(rr, req)
+
+	if form.Get("name") != "%web%" || form.Get("sort") != "-created_at" || form.Get("start") != "5" || form.Get("count") != "5" {
+		t.Errorf("Unexpected search %v", form)
+	}
+	body := rr.Body.String()
+	for _, expected := range []string{"web-01.example.com", "Servers 6 to 6 of 12", "&laquo; Previous", "Next &raquo;",
+		`value="web"`, `<option value="5" selected>`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected '%s'. Got %s", expected, body)
+		}
+	}
+}
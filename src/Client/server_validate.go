@@ -1,10 +1,13 @@
 package main
 
-import "regexp"
-
-var serverRegExp = regexp.MustCompile(`^\w*\.\w*\.\w*$`)
+import (
+	"admin-server/validation"
+)
 
+// serverNameValid reports whether the server name is valid by the REST server's
+// default rules. The REST server has the final say, as it may be configured
+// with stricter rules.
 func serverNameValid(serverName string) bool {
-
-	return serverRegExp.MatchString(serverName)
+	_, violations := validation.DefaultRules().ServerName(serverName)
+	return len(violations) == 0
 }
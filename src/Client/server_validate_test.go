@@ -19,3 +19,15 @@ func TestServerIP(t *testing.T) {
 		t.Error("FQDN passed!")
 	}
 }
+
+func TestDeepServer(t *testing.T) {
+	if !serverNameValid("Web-01.prod.eu.example.com") {
+		t.Error("Valid deep server name failed!")
+	}
+}
+
+func TestUnqualifiedServer(t *testing.T) {
+	if serverNameValid("localhost") {
+		t.Error("Unqualified server name passed!")
+	}
+}
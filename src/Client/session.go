@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"admin-server/identity"
+	"admin-server/middleware"
+	"admin-server/throttle"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const sessionCookieName = "sadmin_session"
+const loginCSRFCookieName = "sadmin_login_csrf"
+const csrfFieldName = "csrf_token"
+
+const defaultSessionIdleTimeout = 20 * time.Minute
+const defaultSessionAbsoluteTimeout = 8 * time.Hour
+
+// A session represents a signed-in user of the web interface.
+type session struct {
+	ID        string
+	User      string
+	Role      string
+	CSRFToken string
+	Created   time.Time
+	LastSeen  time.Time
+
+	flashes []flash // guarded by the store's mutex
+}
+
+// hasRole reports whether the session's user has at least the specified role:
+// viewers may only list servers, editors may also change them and admins may
+// also delete them.
+func (s *session) hasRole(role string) bool {
+	return identity.Identity{User: s.User, Role: s.Role}.HasRole(role)
+}
+
+// sessionStore holds the active sessions in memory; they do not
+// survive a restart of the web client.
+type sessionStore struct {
+	mu              sync.Mutex
+	sessions        map[string]*session
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+	now             func() time.Time
+}
+
+func newSessionStore(idleTimeout, absoluteTimeout time.Duration) *sessionStore {
+	return &sessionStore{
+		sessions:        map[string]*session{},
+		idleTimeout:     idleTimeout,
+		absoluteTimeout: absoluteTimeout,
+		now:             time.Now,
+	}
+}
+
+var sessions = newSessionStore(defaultSessionIdleTimeout, defaultSessionAbsoluteTimeout)
+
+// randomToken returns 32 bytes of randomness, URL-safe encoded.
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// Without randomness no session can be secure
+		log.Panicf("randomToken - Error on rand.Read: '%v'", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (st *sessionStore) expired(s *session, now time.Time) bool {
+	return now.Sub(s.LastSeen) > st.idleTimeout || now.Sub(s.Created) > st.absoluteTimeout
+}
+
+func (st *sessionStore) create(user, role string) *session {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := st.now()
+	for id, s := range st.sessions {
+		if st.expired(s, now) {
+			delete(st.sessions, id)
+		}
+	}
+
+	s := &session{
+		ID:        randomToken(),
+		User:      user,
+		Role:      role,
+		CSRFToken: randomToken(),
+		Created:   now,
+		LastSeen:  now,
+	}
+	st.sessions[s.ID] = s
+	return s
+}
+
+// get returns the session for the specified ID, refreshing its idle timer,
+// or nil if there is no such session or it has expired.
+func (st *sessionStore) get(id string) *session {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.sessions[id]
+	if !ok {
+		return nil
+	}
+	now := st.now()
+	if st.expired(s, now) {
+		delete(st.sessions, id)
+		return nil
+	}
+	s.LastSeen = now
+	return s
+}
+
+// active reports whether the session with the specified ID exists and has not
+// expired, without refreshing its idle timer.
+func (st *sessionStore) active(id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.sessions[id]
+	return ok && !st.expired(s, st.now())
+}
+
+// addFlash queues a message for the next page shown to the session's user.
+func (st *sessionStore) addFlash(s *session, f flash) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s.flashes = append(s.flashes, f)
+}
+
+// takeFlashes returns the queued messages, which are then forgotten.
+func (st *sessionStore) takeFlashes(s *session) []flash {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	flashes := s.flashes
+	s.flashes = nil
+	return flashes
+}
+
+func (st *sessionStore) destroy(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	delete(st.sessions, id)
+}
+
+type contextKey int
+
+const (
+	sessionContextKey contextKey = iota
+	streamsContextKey
+)
+
+// sessionFromRequest returns the session attached by requireSession.
+func sessionFromRequest(r *http.Request) *session {
+	s, _ := r.Context().Value(sessionContextKey).(*session)
+	return s
+}
+
+// csrfToken returns the CSRF token to embed in forms rendered for this request.
+func csrfToken(r *http.Request) string {
+	if s := sessionFromRequest(r); s != nil {
+		return s.CSRFToken
+	}
+	return ""
+}
+
+func tokensMatch(expected, actual string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+func setCookie(w http.ResponseWriter, name, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Requests per second allowed for each client IP and user
+const requestRate = 10
+const requestBurst = 30
+
+// setRetryAfter tells the client how long to wait, in whole seconds.
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(throttle.RetryAfter(wait)))
+}
+
+// requireSession only delegates to the given handle for signed-in users, up
+// to the rate th allows them, and for anything other than GET it also
+// requires a valid CSRF token.
+func requireSession(th *throttle.Throttle, h httprouter.Handle) httprouter.Handle {
+
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		var s *session
+		if c, err := req.Cookie(sessionCookieName); err == nil {
+			s = sessions.get(c.Value)
+		}
+		if s == nil {
+			http.Redirect(w, req, "/login", http.StatusSeeOther)
+			return
+		}
+
+		if ok, wait := th.Allow("ip:"+middleware.ClientIP(req), "user:"+s.User); !ok {
+			slog.WarnContext(req.Context(), "requireSession - Throttled", "user", s.User, "ip", middleware.ClientIP(req), "wait", wait.String())
+			setRetryAfter(w, wait)
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		if req.Method != "GET" && req.Method != "HEAD" {
+			if !tokensMatch(s.CSRFToken, req.PostFormValue(csrfFieldName)) {
+				slog.WarnContext(req.Context(), "requireSession - Invalid CSRF token", "user", s.User)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+
+		h(w, req.WithContext(context.WithValue(req.Context(), sessionContextKey, s)), ps)
+	}
+}
+
+// requireRole is requireSession for users with at least the specified role.
+func requireRole(th *throttle.Throttle, role string, h httprouter.Handle) httprouter.Handle {
+
+	return requireSession(th, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		s := sessionFromRequest(req)
+		if !s.hasRole(role) {
+			slog.WarnContext(req.Context(), "requireRole - Insufficient role", "user", s.User, "role", s.Role, "required", role)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h(w, req, ps)
+	})
+}
+
+type loginPageVars struct {
+	User        string
+	Invalid     bool
+	Local       bool
+	SSO         bool
+	ErrorString string
+	CSRFToken   string
+}
+
+// loginPage is the sign-in page, guarded by the double-submit token.
+func loginPage(token string) loginPageVars {
+	return loginPageVars{Local: conf.Auth.User != "", SSO: oidcAuth != nil, CSRFToken: token}
+}
+
+func newLoginPage(writer http.ResponseWriter) loginPageVars {
+	// Guard the login form itself with a double-submit token
+	token := randomToken()
+	setCookie(writer, loginCSRFCookieName, token, 0)
+	return loginPage(token)
+}
+
+func showLoginForm(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+	page := newLoginPage(writer)
+	render(writer, request, "login.gohtml", page)
+}
+
+func showLoginError(writer http.ResponseWriter, request *http.Request, code int, message string) {
+	page := newLoginPage(writer)
+	page.ErrorString = message
+	renderStatus(writer, request, code, "login.gohtml", page)
+}
+
+// login signs in with the local account. Failed attempts are counted under
+// keys of their own, so that they lock out those guessing at the account from
+// signing in, but never the sessions of the account's user.
+func login(th *throttle.Throttle) httprouter.Handle {
+	return func(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+
+		c, err := request.Cookie(loginCSRFCookieName)
+		if err != nil || !tokensMatch(c.Value, request.PostFormValue(csrfFieldName)) {
+			slog.WarnContext(request.Context(), "login - Invalid CSRF token")
+			http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		user := request.PostFormValue("user")
+		password := request.PostFormValue("password")
+
+		ipKey, userKey := "login-ip:"+middleware.ClientIP(request), "login:"+user
+		if ok, wait := th.Allow(ipKey); !ok {
+			slog.WarnContext(request.Context(), "login - Throttled", "user", user, "ip", middleware.ClientIP(request), "wait", wait.String())
+			page := loginPage(c.Value)
+			page.User = user
+			page.ErrorString = fmt.Sprintf("Too many attempts, please try again in %v.", wait.Round(time.Second))
+			setRetryAfter(writer, wait)
+			renderStatus(writer, request, http.StatusTooManyRequests, "login.gohtml", page)
+			return
+		}
+
+		// Compare both fields in full so that timing reveals nothing
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(conf.Auth.User)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(password), []byte(conf.Auth.Password)) == 1
+		if !userOK || !passOK || conf.Auth.User == "" {
+			// The account's failures lock out those guessing at it, never the right password
+			lockout := th.Fail(ipKey, userKey)
+			th.Lock(lockout, ipKey)
+			slog.WarnContext(request.Context(), "Auth failure", "user", user, "ip", middleware.ClientIP(request), "lockout", lockout.String())
+			page := loginPage(c.Value)
+			page.User, page.Invalid = user, true
+			renderStatus(writer, request, http.StatusUnauthorized, "login.gohtml", page)
+			return
+		}
+		th.Succeed(ipKey, userKey)
+
+		// The local account is the administrator
+		s := sessions.create(user, identity.RoleAdmin)
+		setCookie(writer, loginCSRFCookieName, "", -1)
+		setCookie(writer, sessionCookieName, s.ID, 0)
+
+		slog.InfoContext(request.Context(), "Logged in", "user", user, "role", identity.RoleAdmin)
+
+		http.Redirect(writer, request, "/Servers", http.StatusSeeOther)
+	}
+}
+
+func logout(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+	s := sessionFromRequest(request)
+	sessions.destroy(s.ID)
+	setCookie(writer, sessionCookieName, "", -1)
+
+	slog.InfoContext(request.Context(), "Logged out", "user", s.User)
+
+	http.Redirect(writer, request, "/login", http.StatusSeeOther)
+}
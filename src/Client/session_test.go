@@ -0,0 +1,215 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"admin-server/identity"
+	"admin-server/throttle"
+)
+
+func TestSessionIdleTimeout(t *testing.T) {
+	now := time.Now()
+	st := newSessionStore(time.Minute, time.Hour)
+	st.now = func() time.Time { return now }
+
+	s := st.create("auth_user", identity.RoleAdmin)
+
+	now = now.Add(59 * time.Second)
+	if st.get(s.ID) == nil {
+		t.Error("Session expired before idle timeout!")
+	}
+
+	now = now.Add(61 * time.Second)
+	if st.get(s.ID) != nil {
+		t.Error("Session survived idle timeout!")
+	}
+}
+
+func TestSessionAbsoluteTimeout(t *testing.T) {
+	now := time.Now()
+	st := newSessionStore(time.Minute, 3*time.Minute)
+	st.now = func() time.Time { return now }
+
+	s := st.create("auth_user", identity.RoleAdmin)
+
+	// Keep the session active
+	for i := 0; i < 3; i++ {
+		now = now.Add(50 * time.Second)
+		if st.get(s.ID) == nil {
+			t.Errorf("Session expired early after %d refreshes!", i)
+		}
+	}
+
+	now = now.Add(50 * time.Second)
+	if st.get(s.ID) != nil {
+		t.Error("Session survived absolute timeout!")
+	}
+}
+
+func TestNoSessionRedirectsToLogin(t *testing.T) {
+	req := httptest.NewRequest("GET", "/createServer", nil)
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/login" {
+		t.Errorf("Expected redirect to /login. Got %d '%s'", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestPostWithoutCSRFToken(t *testing.T) {
+	s := sessions.create("auth_user", identity.RoleAdmin)
+	defer sessions.destroy(s.ID)
+
+	form := url.Values{"name": {"srv.example.com"}}
+	req := httptest.NewRequest("POST", "/createServer", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: s.ID})
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected response code %d. Got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	conf.Auth.User, conf.Auth.Password = "auth_user", "secret"
+	router := newRouter()
+
+	form := url.Values{"user": {"auth_user"}, "password": {"wrong"}, csrfFieldName: {"token"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: loginCSRFCookieName, Value: "token"})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Bad password - Expected response code %d. Got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	form.Set("password", "secret")
+	req = httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: loginCSRFCookieName, Value: "token"})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("Login - Expected response code %d. Got %d", http.StatusSeeOther, rr.Code)
+	}
+	var s *session
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			if !c.HttpOnly || !c.Secure {
+				t.Error("Session cookie must be HttpOnly and Secure!")
+			}
+			s = sessions.get(c.Value)
+		}
+	}
+	if s == nil {
+		t.Fatal("Login - No session created!")
+	}
+
+	form = url.Values{csrfFieldName: {s.CSRFToken}}
+	req = httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: s.ID})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("Logout - Expected response code %d. Got %d", http.StatusSeeOther, rr.Code)
+	}
+	if sessions.get(s.ID) != nil {
+		t.Error("Logout - Session still active!")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	conf.Auth.User, conf.Auth.Password = "auth_user", "secret"
+	router := newRouterWith(throttle.New(requestRate, requestBurst))
+
+	post := func(ip, password string) *httptest.ResponseRecorder {
+		form := url.Values{"user": {"auth_user"}, "password": {password}, csrfFieldName: {"token"}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":1234"
+		req.AddCookie(&http.Cookie{Name: loginCSRFCookieName, Value: "token"})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	get := func(ip string, cookie *http.Cookie) int {
+		req := httptest.NewRequest("GET", "/createServer", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// The administrator signs in
+	rr := post("192.0.2.10", "secret")
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatalf("Expected to sign in. Got %d", rr.Code)
+	}
+	t.Cleanup(func() { sessions.destroy(cookie.Value) })
+
+	// Someone else guesses at the password
+	for i := 0; i < throttle.FreeFailures+1; i++ {
+		expected := http.StatusUnauthorized
+		if i == throttle.FreeFailures {
+			expected = http.StatusTooManyRequests
+		}
+		if rr := post("192.0.2.99", "guess"); rr.Code != expected {
+			t.Errorf("Failure %d - Expected response code %d. Got %d", i+1, expected, rr.Code)
+		}
+	}
+
+	// And is locked out, even with the right password
+	rr = post("192.0.2.99", "secret")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected response code %d. Got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After to be '1'. Got '%s'", rr.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rr.Body.String(), `name="password"`) {
+		t.Error("Expected the sign-in form")
+	}
+
+	// But the administrator's session is not, from wherever it is used
+	for _, ip := range []string{"192.0.2.10", "192.0.2.99"} {
+		if code := get(ip, cookie); code != http.StatusOK {
+			t.Errorf("%s - Expected the session to be unaffected. Got %d", ip, code)
+		}
+	}
+}
+
+func TestLoginPageWithoutLocalAccount(t *testing.T) {
+	conf.Auth.User, conf.Auth.Password = "", ""
+	t.Cleanup(func() { conf.Auth.User, conf.Auth.Password = "auth_user", "secret" })
+	router := newRouterWith(throttle.New(requestRate, requestBurst))
+
+	form := url.Values{"user": {"someone"}, "password": {"guess"}, csrfFieldName: {"token"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: loginCSRFCookieName, Value: "token"})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized || strings.Contains(rr.Body.String(), `name="password"`) {
+		t.Errorf("Expected no password form without a local account. Got %d %s", rr.Code, rr.Body.String())
+	}
+}
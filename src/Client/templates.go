@@ -0,0 +1,246 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+
+	"admin-server/identity"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// The templates and static assets are built into the binary, so that it runs
+// from any directory. Files in the override directories, if configured, take
+// the place of the built-in ones of the same name.
+
+//go:embed templates/*.gohtml
+var builtinTemplates embed.FS
+
+//go:embed assets
+var builtinAssets embed.FS
+
+// overlayFS serves files from override, if set, and otherwise from base.
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+// newOverlayFS returns the built-in files in the named subdirectory of
+// builtin, overridden by those in dir, if it is not blank.
+func newOverlayFS(builtin embed.FS, subdir, dir string) overlayFS {
+	base, _ := fs.Sub(builtin, subdir)
+	o := overlayFS{base: base}
+	if dir != "" {
+		o.override = os.DirFS(dir)
+	}
+	return o
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if o.override != nil {
+		f, err := o.override.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return o.base.Open(name)
+}
+
+// glob returns the names matching the pattern in either file system.
+func (o overlayFS) glob(pattern string) ([]string, error) {
+	names, err := fs.Glob(o.base, pattern)
+	if err != nil || o.override == nil {
+		return names, err
+	}
+	overrides, err := fs.Glob(o.override, pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range overrides {
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// assets returns the static assets, overridden by those in dir.
+func assets(dir string) http.FileSystem {
+	return http.FS(newOverlayFS(builtinAssets, "assets", dir))
+}
+
+// Each page template defines a "title" and its "content", which the "layout"
+// template puts in the navigation shared by every page. The other templates,
+// such as the layout itself and problem.gohtml, are partials that any page may
+// use.
+var pageTemplates map[string]*template.Template
+
+// loadTemplates parses the page templates, overridden by those in dir.
+func loadTemplates(dir string) error {
+	t, err := parseTemplates(dir)
+	if err != nil {
+		return err
+	}
+	pageTemplates = t
+	return nil
+}
+
+// templates returns the page templates to render with. In dev mode they are
+// parsed again for each page, so that changes show without a restart.
+func templates() (map[string]*template.Template, error) {
+	if conf.DevMode {
+		return parseTemplates(conf.Templates)
+	}
+	return pageTemplates, nil
+}
+
+func parseTemplates(dir string) (map[string]*template.Template, error) {
+	fsys := newOverlayFS(builtinTemplates, "templates", dir)
+	files, err := fsys.glob("*.gohtml")
+	if err != nil {
+		return nil, err
+	}
+	var pages, partials []string
+	for _, file := range files {
+		t, err := template.ParseFS(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		if t.Lookup("content") != nil {
+			pages = append(pages, file)
+		} else {
+			partials = append(partials, file)
+		}
+	}
+
+	base, err := template.ParseFS(fsys, partials...)
+	if err != nil {
+		return nil, err
+	}
+	if base.Lookup("layout") == nil {
+		return nil, fmt.Errorf("no layout template")
+	}
+	loaded := map[string]*template.Template{}
+	for _, page := range pages {
+		t, err := template.Must(base.Clone()).ParseFS(fsys, page)
+		if err != nil {
+			return nil, err
+		}
+		loaded[page] = t
+	}
+	return loaded, nil
+}
+
+// Themes are the colour schemes a user may choose; auto follows the browser.
+var themes = []string{"auto", "light", "dark"}
+
+const themeCookieName = "sadmin_theme"
+
+// A flash is a one-off message shown at the top of the next page.
+type flash struct {
+	Kind string // flashSuccess or flashError
+	Text string
+}
+
+const (
+	flashSuccess = "success"
+	flashError   = "error"
+)
+
+// redirectWithFlash redirects after a successful form, so that reloading the
+// page does not submit the form again, showing the message on the next page.
+func redirectWithFlash(writer http.ResponseWriter, request *http.Request, target string, f flash) {
+	if s := sessionFromRequest(request); s != nil {
+		sessions.addFlash(s, f)
+	}
+	http.Redirect(writer, request, target, http.StatusSeeOther)
+}
+
+// layoutVars are what the layout needs, around the variables of the page.
+type layoutVars struct {
+	Page      interface{}
+	User      string
+	Role      string
+	CanEdit   bool
+	CSRFToken string
+	Theme     string
+	Themes    []string
+	Flashes   []flash
+}
+
+// refreshHeader marks a request by live.js to read the server list again in
+// place, which shows no flashes, so leaves them for the next page.
+const refreshHeader = "X-Sadmin-Refresh"
+
+func newLayout(request *http.Request, page interface{}) layoutVars {
+	l := layoutVars{Page: page, Theme: themeFromRequest(request), Themes: themes}
+	if s := sessionFromRequest(request); s != nil {
+		l.User, l.Role, l.CSRFToken = s.User, s.Role, s.CSRFToken
+		l.CanEdit = s.hasRole(identity.RoleEditor)
+		if request.Header.Get(refreshHeader) == "" {
+			l.Flashes = sessions.takeFlashes(s)
+		}
+	}
+	return l
+}
+
+// themeFromRequest returns the theme chosen by the user, by default auto.
+func themeFromRequest(request *http.Request) string {
+	if c, err := request.Cookie(themeCookieName); err == nil {
+		for _, t := range themes {
+			if c.Value == t {
+				return t
+			}
+		}
+	}
+	return themes[0]
+}
+
+// setTheme remembers the chosen theme for a year, then returns the user to the
+// page they chose it on. The theme is only a preference, so even signed-out
+// users may choose one.
+func setTheme(writer http.ResponseWriter, request *http.Request, ps httprouter.Params) {
+	theme := request.PostFormValue("theme")
+	valid := false
+	for _, t := range themes {
+		valid = valid || theme == t
+	}
+	if !valid {
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(writer, &http.Cookie{
+		Name:     themeCookieName,
+		Value:    theme,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(writer, request, backTo(request), http.StatusSeeOther)
+}
+
+// backTo returns the path of the same-host page the request came from, if
+// any, else the server list.
+func backTo(request *http.Request) string {
+	if u, err := url.Parse(request.Referer()); err == nil && u.Host == request.Host && u.Path != "" {
+		return u.RequestURI()
+	}
+	return "/Servers"
+}
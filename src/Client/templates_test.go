@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"admin-server/identity"
+)
+
+func TestEveryPageHasLayout(t *testing.T) {
+	for _, name := range []string{"bulk.gohtml", "createServer.gohtml", "deleteServer.gohtml", "editServer.gohtml",
+		"error.gohtml", "login.gohtml", "serverDetail.gohtml", "serverList.gohtml"} {
+		if pageTemplates[name] == nil {
+			t.Errorf("Expected page %s to be loaded", name)
+		}
+	}
+	if pageTemplates["layout.gohtml"] != nil || pageTemplates["problem.gohtml"] != nil {
+		t.Errorf("Expected partials not to be pages")
+	}
+}
+
+func TestLayoutNavigation(t *testing.T) {
+	fakeRemote(t, map[string]string{"7": "web-01.example.com"})
+
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, editorRequest(t, "GET", "/servers/7", url.Values{}))
+
+	body := rr.Body.String()
+	for _, want := range []string{`<title>Server Entry - Sadmin</title>`, `name="viewport"`,
+		`href="/createServer"`, "editor (editor)", `value="Sign Out"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the layout to contain %s. Got %s", want, body)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, httptest.NewRequest("GET", "/login", nil))
+	if body := rr.Body.String(); strings.Contains(body, "Sign Out") || strings.Contains(body, "/createServer") {
+		t.Errorf("Expected no navigation before signing in. Got %s", body)
+	}
+}
+
+func TestTheme(t *testing.T) {
+	req := httptest.NewRequest("POST", "/theme", strings.NewReader("theme=dark"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", "https://"+req.Host+"/servers/7?x=1")
+	rr := httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/servers/7?x=1" ||
+		len(cookies) != 1 || cookies[0].Name != themeCookieName || cookies[0].Value != "dark" {
+		t.Fatalf("Expected the theme to be remembered. Got %d %v", rr.Code, rr.Header())
+	}
+
+	req = httptest.NewRequest("GET", "/login", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+	if body := rr.Body.String(); !strings.Contains(body, `data-theme="dark"`) {
+		t.Errorf("Expected the dark theme. Got %s", body)
+	}
+
+	req = httptest.NewRequest("POST", "/theme", strings.NewReader("theme=purple"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	newRouter().ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown theme to be rejected. Got %d", rr.Code)
+	}
+}
+
+func TestTemplateAndAssetOverrides(t *testing.T) {
+	dir := t.TempDir()
+	page := `{{define "title"}}Sign In{{end}}{{define "content"}}<h1>Welcome to ACME</h1>{{end}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "login.gohtml"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "logo.svg"), []byte("<svg/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(c config) { conf = c }(conf)
+	conf.Templates, conf.Assets, conf.DevMode = dir, dir, true
+
+	for path, want := range map[string]string{
+		"/login":            "Welcome to ACME",
+		"/static/logo.svg":  "<svg/>",
+		"/static/style.css": "--background",
+	} {
+		rr := httptest.NewRecorder()
+		newRouter().ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("%s - Expected '%s'. Got %d %s", path, want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestRefreshKeepsFlashes(t *testing.T) {
+	s := sessions.create("editor", identity.RoleEditor)
+	t.Cleanup(func() { sessions.destroy(s.ID) })
+	sessions.addFlash(s, flash{flashSuccess, "Saved web-01.example.com"})
+
+	req := httptest.NewRequest("GET", "/Servers", nil)
+	req = req.WithContext(context.WithValue(req.Context(), sessionContextKey, s))
+	req.Header.Set(refreshHeader, "1")
+	if l := newLayout(req, nil); len(l.Flashes) != 0 {
+		t.Errorf("Expected a refresh to show no flashes. Got %v", l.Flashes)
+	}
+
+	req.Header.Del(refreshHeader)
+	if l := newLayout(req, nil); len(l.Flashes) != 1 {
+		t.Errorf("Expected the flash to be kept for the next page. Got %v", l.Flashes)
+	}
+}
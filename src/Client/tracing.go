@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"admin-server/tracing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// traceTransport traces requests to the REST server, passing on the trace context.
+func traceTransport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(rt, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+		return req.Method + " " + req.URL.Path
+	}))
+}
+
+// render executes the named page template within the layout, in a span of its own.
+func render(writer http.ResponseWriter, request *http.Request, name string, page interface{}) {
+	renderStatus(writer, request, http.StatusOK, name, page)
+}
+
+// renderStatus is render with another status than 200 OK. The page is only
+// sent once it has been rendered in full, so that if rendering fails, the user
+// gets an error rather than half a page.
+func renderStatus(writer http.ResponseWriter, request *http.Request, status int, name string, page interface{}) {
+	_, span := tracing.Tracer().Start(request.Context(), "render "+name)
+	defer span.End()
+
+	var b bytes.Buffer
+	t, err := templates()
+	if err == nil {
+		if tmpl, ok := t[name]; ok {
+			err = tmpl.ExecuteTemplate(&b, "layout", newLayout(request, page))
+		} else {
+			err = fmt.Errorf("no template named %s", name)
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.ErrorContext(request.Context(), "render - Error on ExecuteTemplate", "template", name, "error", err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	writer.WriteHeader(status)
+	b.WriteTo(writer)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"admin-server/tracing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	if _, err := tracing.Setup(context.Background(), "test", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var traceparent string
+	remote := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+	conf.Remote.Host, conf.Remote.Port = u.Hostname(), u.Port()
+	router := newRouter()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/readyz", nil))
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected a REST call span and a request span. Got %d spans", len(spans))
+	}
+	call, request := spans[0], spans[1]
+	if request.Name != "GET /readyz" || call.Name != "GET /healthz" {
+		t.Errorf("Unexpected span names '%s' and '%s'", request.Name, call.Name)
+	}
+	if call.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Errorf("Expected the REST call span to be a child of the request span")
+	}
+	if want := "00-" + call.SpanContext.TraceID().String() + "-" + call.SpanContext.SpanID().String() + "-01"; traceparent != want {
+		t.Errorf("Expected the REST server to receive traceparent '%s'. Got '%s'", want, traceparent)
+	}
+
+	exporter.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/login", nil))
+	spans = exporter.GetSpans()
+	if len(spans) != 2 || spans[0].Name != "render login.gohtml" || spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Errorf("Expected a render span within the request span. Got %d spans", len(spans))
+	}
+}
+
+func TestRenderFailure(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	renderStatus(rr, req, http.StatusNotFound, "missing.gohtml", nil)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a missing template to be an error. Got %d", rr.Code)
+	}
+
+	// Nothing of a page which fails part way through is sent
+	rr = httptest.NewRecorder()
+	render(rr, req, "serverDetail.gohtml", "not the page's variables")
+	if body := rr.Body.String(); rr.Code != http.StatusInternalServerError || strings.Contains(body, "<html") {
+		t.Errorf("Expected only an error. Got %d %s", rr.Code, body)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// bashCompletion completes sadminctl commands and flags in bash, and in zsh
+// through bashcompinit.
+const bashCompletion = `_sadminctl() {
+    local cur prev
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+        -o)
+            COMPREPLY=($(compgen -W "table json yaml" -- "$cur"))
+            return ;;
+        -config|-ca-cert)
+            COMPREPLY=($(compgen -f -- "$cur"))
+            return ;;
+    esac
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "-url -user -ca-cert -insecure -timeout -o -config -start -count" -- "$cur"))
+        return
+    fi
+    case "$COMP_CWORD" in
+        1) COMPREPLY=($(compgen -W "servers completion" -- "$cur")) ;;
+        2)
+            case "$prev" in
+                servers) COMPREPLY=($(compgen -W "list get create update delete search" -- "$cur")) ;;
+                completion) COMPREPLY=($(compgen -W "bash zsh" -- "$cur")) ;;
+            esac ;;
+    esac
+}
+complete -F _sadminctl sadminctl
+`
+
+const zshPreamble = `autoload -U +X compinit && compinit
+autoload -U +X bashcompinit && bashcompinit
+`
+
+// completion prints the shell completion script, returning the exit code.
+func completion(shell string, stdout, stderr io.Writer) int {
+	switch shell {
+	case "bash":
+		fmt.Fprint(stdout, bashCompletion)
+	case "zsh":
+		fmt.Fprint(stdout, zshPreamble+bashCompletion)
+	default:
+		fmt.Fprintf(stderr, "sadminctl: no completion for '%s', only bash and zsh\n", shell)
+		return exitUsage
+	}
+	return exitOK
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	// local import
+	"admin-server/settings"
+)
+
+// ctlConfig is where the REST server is and how to talk to it.
+//
+// Each setting is taken from, in increasing order of precedence: its default,
+// the config file (named by the -config flag or SADMINCTL_CONFIG, by default
+// sadminctl/config.yml in the user's config directory), its environment
+// variable, and its command-line flag, as described in package settings. The
+// password has no flag, so as not to appear in process listings or shell history.
+type ctlConfig struct {
+	URL      string        `yaml:"url"`
+	User     string        `yaml:"user"`
+	Password string        `yaml:"password"`
+	CACert   string        `yaml:"ca_cert"`
+	Insecure bool          `yaml:"insecure"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Output   string        `yaml:"output"`
+}
+
+func defaultConfig() ctlConfig {
+	return ctlConfig{
+		URL:     "https://localhost:8100",
+		Timeout: 10 * time.Second,
+		Output:  "table",
+	}
+}
+
+// Settings are the settings of sadminctl.
+func (c *ctlConfig) Settings() []settings.Setting {
+	return []settings.Setting{
+		settings.Flag("url", "SADMIN_URL", settings.String(&c.URL), "REST server URL"),
+		settings.Flag("user", "SADMIN_USER", settings.String(&c.User), "API user, needed for changes"),
+		settings.Secret("SADMIN_PASSWORD", settings.String(&c.Password)),
+		settings.Flag("ca-cert", "SADMIN_CA_CERT", settings.String(&c.CACert), "CA certificate file to trust the REST server's certificate"),
+		settings.Flag("insecure", "SADMIN_INSECURE", settings.Bool(&c.Insecure), "skip verifying the REST server's certificate"),
+		settings.Flag("timeout", "SADMIN_TIMEOUT", settings.Duration(&c.Timeout), "maximum time for each request"),
+		settings.Flag("o", "SADMIN_OUTPUT", settings.String(&c.Output), "output format: table, json or yaml"),
+	}
+}
+
+// defaultConfigFile is the config file used if none is named.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sadminctl", "config.yml")
+}
+
+// addFlags adds the configuration flags to the flag set, returning the config file flag.
+func (c *ctlConfig) addFlags(fs *flag.FlagSet) *string {
+	configFile := fs.String("config", os.Getenv("SADMINCTL_CONFIG"), "YAML config file (env SADMINCTL_CONFIG)")
+	settings.AddFlags(fs, c)
+	return configFile
+}
+
+// load completes the configuration once the flag set has been parsed.
+func (c *ctlConfig) load(fs *flag.FlagSet, configFile string) error {
+	explicit := configFile != ""
+	if !explicit {
+		configFile = defaultConfigFile()
+	}
+	if configFile != "" {
+		if err := settings.LoadFile(configFile, c); err != nil && (explicit || !os.IsNotExist(err)) {
+			return err
+		}
+	}
+
+	if err := settings.Apply(fs, c); err != nil {
+		return err
+	}
+	return c.validate()
+}
+
+func (c *ctlConfig) validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("the REST server URL (SADMIN_URL) is required")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout (SADMIN_TIMEOUT) must be positive, not %v", c.Timeout)
+	}
+	switch c.Output {
+	case "table", "json", "yaml":
+	default:
+		return fmt.Errorf("output (SADMIN_OUTPUT) must be table, json or yaml, not '%s'", c.Output)
+	}
+	return nil
+}
+
+// httpClient returns an HTTP client trusting the configured certificates.
+func (c *ctlConfig) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CACert != "" {
+		pem, err := ioutil.ReadFile(c.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", c.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Client{
+		Timeout:   c.Timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+	}, nil
+}
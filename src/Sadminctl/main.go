@@ -0,0 +1,173 @@
+// sadminctl manages the server inventory from the command line, through the
+// REST server.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+
+	// local import
+	"admin-server/sadmin"
+)
+
+const usage = `usage: sadminctl servers COMMAND [flags] [arguments]
+
+Commands:
+    servers list [-start N] [-count N]            list servers, in order of name
+    servers get ID                                show a server
+    servers create NAME                           add a server
+    servers update ID NAME                        rename a server
+    servers delete ID                             remove a server
+    servers search PATTERN [-start N] [-count N]  list servers whose names match a
+                                                  SQL LIKE pattern, such as 'web-%'
+    completion bash|zsh                           print a shell completion script
+
+Flags may be given anywhere after the command:
+`
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// Most servers the REST server returns at a time
+const maxCount = 25
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// parseInterleaved parses flags appearing anywhere among the arguments,
+// returning the other arguments.
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return rest, nil
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// run runs the command line, returning the exit code.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 2 && args[0] == "completion" {
+		return completion(args[1], stdout, stderr)
+	}
+	if len(args) < 2 || args[0] != "servers" {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	command := args[1]
+
+	conf := defaultConfig()
+	fs := flag.NewFlagSet("sadminctl servers "+command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	configFile := conf.addFlags(fs)
+	start := fs.Int("start", 0, "number of servers to skip")
+	count := fs.Int("count", maxCount, "most servers to list")
+
+	operands, err := parseInterleaved(fs, args[2:])
+	if err != nil {
+		return exitUsage
+	}
+	if err := conf.load(fs, *configFile); err != nil {
+		fmt.Fprintln(stderr, "sadminctl:", err)
+		return exitUsage
+	}
+
+	wantArgs := map[string]int{"list": 0, "get": 1, "create": 1, "update": 2, "delete": 1, "search": 1}
+	n, ok := wantArgs[command]
+	if !ok || len(operands) != n {
+		fs.Usage()
+		return exitUsage
+	}
+	var id int64
+	if command == "get" || command == "update" || command == "delete" {
+		if id, err = strconv.ParseInt(operands[0], 10, 64); err != nil {
+			fmt.Fprintf(stderr, "sadminctl: invalid server ID '%s'\n", operands[0])
+			return exitUsage
+		}
+	}
+
+	httpClient, err := conf.httpClient()
+	if err != nil {
+		fmt.Fprintln(stderr, "sadminctl:", err)
+		return exitUsage
+	}
+	c := sadmin.New(conf.URL, httpClient)
+	c.User, c.Password = conf.User, conf.Password
+
+	var result interface{}
+	switch command {
+	case "list":
+		result, err = c.List(ctx, *start, *count)
+	case "get":
+		result, err = c.Get(ctx, id)
+	case "create":
+		result, err = c.Create(ctx, operands[0])
+	case "update":
+		result, err = c.Update(ctx, id, operands[1])
+	case "delete":
+		if err = c.Delete(ctx, id); err == nil {
+			result = deleted{ID: id, Result: "deleted"}
+		}
+	case "search":
+		result, err = c.Search(ctx, operands[0], *start, *count)
+	}
+	if err != nil {
+		printError(stderr, err)
+		return exitError
+	}
+
+	if err := write(stdout, conf.Output, result); err != nil {
+		fmt.Fprintln(stderr, "sadminctl:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// printError describes a failed call, with any details from the REST server.
+func printError(w io.Writer, err error) {
+	var e *sadmin.Error
+	if !errors.As(err, &e) {
+		fmt.Fprintln(w, "sadminctl:", err)
+		return
+	}
+	msg := e.Title
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	fmt.Fprintln(w, "sadminctl:", msg)
+	for _, fe := range e.Errors {
+		fmt.Fprintf(w, "    %s: %s\n", fe.Field, fe.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintln(w, "    request ID:", e.RequestID)
+	}
+	if errors.Is(err, sadmin.ErrUnauthorized) {
+		fmt.Fprintln(w, "    set SADMIN_USER and SADMIN_PASSWORD, or user and password in "+defaultConfigFile())
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeServer answers like the REST server, recording the last request.
+func fakeServer(t *testing.T) (url string, last *http.Request) {
+	last = &http.Request{}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ParseForm()
+		*last = *r.WithContext(context.Background())
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/servers":
+			w.Write([]byte(`[{"id":1,"name":"a.example.com"},{"id":2,"name":"b.example.com"}]`))
+		case r.Method == "POST" && r.URL.Path == "/v1/servers":
+			if u, _, _ := r.BasicAuth(); u != "api" {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"title":"Unauthorized","status":401,"code":"unauthorized","request_id":"req-1"}`))
+				return
+			}
+			var s map[string]interface{}
+			json.Unmarshal(body, &s)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 3, "name": s["name"]})
+		case r.Method == "DELETE" && r.URL.Path == "/v1/servers/3":
+			w.Write([]byte(`{"result":"success"}`))
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title":"Not Found","status":404,"code":"not_found","detail":"Server not found"}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL, last
+}
+
+func isolate(t *testing.T, url string) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("SADMINCTL_CONFIG", "")
+	t.Setenv("SADMIN_URL", url)
+	t.Setenv("SADMIN_INSECURE", "true")
+	t.Setenv("SADMIN_USER", "api")
+	t.Setenv("SADMIN_PASSWORD", "secret")
+}
+
+func runCommand(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestList(t *testing.T) {
+	url, last := fakeServer(t)
+	isolate(t, url)
+
+	code, out, errs := runCommand("servers", "list", "-count", "10")
+	if code != exitOK || !strings.Contains(out, "ID  NAME") || !strings.Contains(out, "2   b.example.com") {
+		t.Errorf("Expected a table. Got %d %s %s", code, out, errs)
+	}
+	if last.FormValue("count") != "10" {
+		t.Errorf("Expected count 10. Got '%s'", last.FormValue("count"))
+	}
+
+	code, out, _ = runCommand("servers", "list", "-o", "json")
+	var servers []map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &servers); code != exitOK || err != nil || len(servers) != 2 {
+		t.Errorf("Expected JSON. Got %d %s", code, out)
+	}
+
+	code, out, _ = runCommand("servers", "list", "-o", "yaml")
+	if code != exitOK || !strings.Contains(out, "- id: 1\n  name: a.example.com\n") {
+		t.Errorf("Expected YAML. Got %d %s", code, out)
+	}
+}
+
+func TestCreateAndDelete(t *testing.T) {
+	url, last := fakeServer(t)
+	isolate(t, url)
+
+	// Flags may follow the arguments
+	code, out, errs := runCommand("servers", "create", "c.example.com", "-o", "json")
+	if code != exitOK || !strings.Contains(out, `"name": "c.example.com"`) {
+		t.Errorf("Expected the created server. Got %d %s %s", code, out, errs)
+	}
+	if u, p, _ := last.BasicAuth(); u != "api" || p != "secret" {
+		t.Errorf("Expected the API user's credentials. Got '%s'", u)
+	}
+
+	code, out, _ = runCommand("servers", "delete", "3")
+	if code != exitOK || out != "Deleted server 3\n" {
+		t.Errorf("Expected the server to be deleted. Got %d %s", code, out)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	url, _ := fakeServer(t)
+	isolate(t, url)
+
+	code, _, errs := runCommand("servers", "get", "9")
+	if code != exitError || !strings.Contains(errs, "Not Found: Server not found (not_found)") {
+		t.Errorf("Expected the problem to be shown. Got %d %s", code, errs)
+	}
+
+	t.Setenv("SADMIN_USER", "someone")
+	code, _, errs = runCommand("servers", "create", "c.example.com")
+	if code != exitError || !strings.Contains(errs, "request ID: req-1") || !strings.Contains(errs, "SADMIN_PASSWORD") {
+		t.Errorf("Expected an authorization error. Got %d %s", code, errs)
+	}
+
+	for _, args := range [][]string{{}, {"servers"}, {"servers", "get"}, {"servers", "get", "x"}, {"servers", "list", "-o", "xml"}, {"completion", "fish"}} {
+		if code, _, _ := runCommand(args...); code != exitUsage {
+			t.Errorf("%v - Expected exit code %d. Got %d", args, exitUsage, code)
+		}
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	url, last := fakeServer(t)
+	isolate(t, "")
+	os.Unsetenv("SADMIN_URL")
+	os.Unsetenv("SADMIN_USER")
+	os.Unsetenv("SADMIN_PASSWORD")
+
+	dir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "sadminctl")
+	os.MkdirAll(dir, 0700)
+	path := filepath.Join(dir, "config.yml")
+	config := "url: " + url + "\nuser: api\npassword: secret\ninsecure: true\n"
+
+	os.WriteFile(path, []byte(config), 0644)
+	if code, _, errs := runCommand("servers", "list"); code != exitUsage || !strings.Contains(errs, "must not be accessible") {
+		t.Errorf("Expected a readable password to be refused. Got %d %s", code, errs)
+	}
+
+	os.Chmod(path, 0600)
+	if code, _, errs := runCommand("servers", "create", "d.example.com"); code != exitOK {
+		t.Errorf("Expected settings from the config file. Got %d %s", code, errs)
+	}
+	if u, _, _ := last.BasicAuth(); u != "api" {
+		t.Errorf("Expected the user from the config file. Got '%s'", u)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh"} {
+		if code, out, _ := runCommand("completion", shell); code != exitOK || !strings.Contains(out, "complete -F _sadminctl sadminctl") {
+			t.Errorf("%s - Expected a completion script. Got %d", shell, code)
+		}
+	}
+}
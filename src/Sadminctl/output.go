@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	// local import
+	"admin-server/sadmin"
+
+	// GitHub packages
+	"gopkg.in/yaml.v3"
+)
+
+// deleted is the result of deleting a server.
+type deleted struct {
+	ID     int64  `json:"id" yaml:"id"`
+	Result string `json:"result" yaml:"result"`
+}
+
+// yamlServer gives servers the same field names in YAML as in JSON.
+type yamlServer struct {
+	ID        int64     `yaml:"id"`
+	Name      string    `yaml:"name"`
+	Status    string    `yaml:"status"`
+	Tags      []string  `yaml:"tags"`
+	CreatedAt time.Time `yaml:"created_at"`
+	UpdatedAt time.Time `yaml:"updated_at"`
+}
+
+// write writes the result of a command in the output format.
+func write(w io.Writer, format string, result interface{}) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case "yaml":
+		return yaml.NewEncoder(w).Encode(forYAML(result))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	switch r := result.(type) {
+	case []sadmin.Server:
+		fmt.Fprintln(tw, "ID\tNAME\tSTATUS")
+		for _, s := range r {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.ID, s.Name, s.Status)
+		}
+	case sadmin.Server:
+		fmt.Fprintln(tw, "ID\tNAME\tSTATUS\tTAGS\tCREATED\tUPDATED")
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Name, r.Status, strings.Join(r.Tags, ","),
+			r.CreatedAt.Format(time.RFC3339), r.UpdatedAt.Format(time.RFC3339))
+	case deleted:
+		fmt.Fprintf(tw, "Deleted server %d\n", r.ID)
+	}
+	return tw.Flush()
+}
+
+func forYAML(result interface{}) interface{} {
+	switch r := result.(type) {
+	case []sadmin.Server:
+		servers := make([]yamlServer, len(r))
+		for i, s := range r {
+			servers[i] = yamlServer(s)
+		}
+		return servers
+	case sadmin.Server:
+		return yamlServer(r)
+	}
+	return result
+}
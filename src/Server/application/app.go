@@ -3,40 +3,60 @@ package application
 
 import (
 	// native packages
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	// local packages
+	"admin-server/config"
+	"admin-server/events"
+	"admin-server/identity"
+	"admin-server/middleware"
 	"admin-server/servers"
+	"admin-server/throttle"
+	"admin-server/validation"
 
 	// GitHub packages
-	"github.com/go-sql-driver/mysql"
 	"github.com/julienschmidt/httprouter"
 )
 
 // App represents the application
 type App struct {
-	Router *httprouter.Router
-	DB     *sql.DB
+	Router      *httprouter.Router
+	DB          *sql.DB
+	Config      *config.Config
+	IdentityKey []byte
+	throttle    *throttle.Throttle
+	metrics     *metrics
+	events      *events.Hub
+	routes      []string
 }
 
+// Authenticated requests per second allowed for each acting user
+const authRate = 50
+const authBurst = 100
+
 func (a *App) getServerEndpoint(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid server ID")
+		respondWithError(w, req, http.StatusBadRequest, codeInvalidID, "Invalid server ID")
 		return
 	}
 	s := servers.Server{ID: int64(id)}
-	if err := s.GetServer(a.DB); err != nil {
+	if err := s.GetServer(req.Context(), a.DB); err != nil {
 		switch err {
 		case sql.ErrNoRows:
-			respondWithError(w, http.StatusNotFound, "Server not found")
+			respondWithError(w, req, http.StatusNotFound, codeNotFound, "Server not found")
 		default:
-			respondWithError(w, http.StatusInternalServerError, err.Error())
+			respondWithInternalError(w, req, "getServerEndpoint - Error on GetServer", err)
 		}
 		return
 	}
@@ -53,71 +73,233 @@ func (a *App) getServersEndpoint(w http.ResponseWriter, req *http.Request, ps ht
 	if start < 0 {
 		start = 0
 	}
-	servers, err := servers.GetServers(a.DB, start, count)
+	sort := req.FormValue("sort")
+	if !servers.ValidSort(sort) {
+		sort = servers.DefaultSort
+	}
+	list, err := servers.GetServers(req.Context(), a.DB, start, count, sort)
+	if err != nil {
+		respondWithInternalError(w, req, "getServersEndpoint - Error on GetServers", err)
+		return
+	}
+	total, err := servers.CountServers(req.Context(), a.DB)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithInternalError(w, req, "getServersEndpoint - Error on CountServers", err)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, servers)
+	w.Header().Set(totalCountHeader, strconv.FormatInt(total, 10))
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// serverInput is a server as written by clients. Fields which are absent are
+// left as they are.
+type serverInput struct {
+	Name   *string   `json:"name"`
+	Status *string   `json:"status"`
+	Tags   *[]string `json:"tags"`
+}
+
+func (in serverInput) applyTo(s *servers.Server) {
+	if in.Name != nil {
+		s.Name = *in.Name
+	}
+	if in.Status != nil {
+		s.Status = *in.Status
+	}
+	if in.Tags != nil {
+		s.Tags = *in.Tags
+	}
+}
+
+// validInput normalizes the fields given, or responds with everything wrong with them.
+func (a *App) validInput(w http.ResponseWriter, req *http.Request, in *serverInput) bool {
+	var violations, v []validation.Violation
+	if in.Name != nil {
+		*in.Name, v = a.Config.ServerNames.ServerName(*in.Name)
+		violations = append(violations, v...)
+	}
+	if in.Status != nil {
+		*in.Status, v = validation.Status(*in.Status)
+		violations = append(violations, v...)
+	}
+	if in.Tags != nil {
+		*in.Tags, v = validation.Tags(*in.Tags)
+		violations = append(violations, v...)
+	}
+	if len(violations) > 0 {
+		respondWithViolations(w, req, violations)
+		return false
+	}
+	return true
 }
 
 func (a *App) createServerEndpoint(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
-	var s servers.Server
+	var in serverInput
 	decoder := json.NewDecoder(req.Body)
-	if err := decoder.Decode(&s); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	if err := decoder.Decode(&in); err != nil {
+		respondWithPayloadError(w, req, err)
 		return
 	}
 	defer req.Body.Close()
-	if err := s.CreateServer(a.DB); err != nil {
-		merr, ok := err.(*mysql.MySQLError)
-		if !ok {
-			respondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-		// Check for Duplicate
-		if merr.Number == 1062 {
-			respondWithError(w, http.StatusConflict, err.Error())
+	// A name is required
+	if in.Name == nil {
+		in.Name = new(string)
+	}
+	if !a.validInput(w, req, &in) {
+		return
+	}
+	s := servers.Server{Status: validation.DefaultStatus, Tags: []string{}}
+	in.applyTo(&s)
+	if err := s.CreateServer(req.Context(), a.DB); err != nil {
+		if isDuplicate(err) {
+			respondWithDuplicate(w, req, s)
 			return
 		}
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithInternalError(w, req, "createServerEndpoint - Error on CreateServer", err)
+		return
+	}
+	// Read back the timestamps set by the database
+	if err := s.GetServer(req.Context(), a.DB); err != nil {
+		respondWithInternalError(w, req, "createServerEndpoint - Error on GetServer", err)
 		return
 	}
+	audit(req, "created", s)
+	a.publish(eventCreated, s)
 	respondWithJSON(w, http.StatusCreated, s)
 }
 
 func (a *App) modifyServerEndpoint(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid server ID")
+		respondWithError(w, req, http.StatusBadRequest, codeInvalidID, "Invalid server ID")
 		return
 	}
-	var s servers.Server
+	var in serverInput
 	decoder := json.NewDecoder(req.Body)
-	if err := decoder.Decode(&s); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	if err := decoder.Decode(&in); err != nil {
+		respondWithPayloadError(w, req, err)
 		return
 	}
 	defer req.Body.Close()
-	s.ID = int64(id)
-	if _, err := s.UpdateServer(a.DB); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+
+	// PUT replaces the name, PATCH changes only what is given
+	if req.Method == "PUT" && in.Name == nil {
+		in.Name = new(string)
+	}
+	if !a.validInput(w, req, &in) {
+		return
+	}
+	s := servers.Server{ID: int64(id)}
+	if err := s.GetServer(req.Context(), a.DB); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			respondWithError(w, req, http.StatusNotFound, codeNotFound, "Server not found")
+		default:
+			respondWithInternalError(w, req, "modifyServerEndpoint - Error on GetServer", err)
+		}
+		return
+	}
+	in.applyTo(&s)
+	if _, err := s.UpdateServer(req.Context(), a.DB); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			respondWithError(w, req, http.StatusNotFound, codeNotFound, "Server not found")
+		case isDuplicate(err):
+			respondWithDuplicate(w, req, s)
+		default:
+			respondWithInternalError(w, req, "modifyServerEndpoint - Error on UpdateServer", err)
+		}
+		return
+	}
+	// Read back the timestamps set by the database
+	if err := s.GetServer(req.Context(), a.DB); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			respondWithError(w, req, http.StatusNotFound, codeNotFound, "Server not found")
+		default:
+			respondWithInternalError(w, req, "modifyServerEndpoint - Error on GetServer", err)
+		}
+		return
+	}
+	audit(req, "modified", s)
+	a.publish(eventModified, s)
+	respondWithJSON(w, http.StatusOK, s)
+}
+
+// tagsInput is tags to add to a server.
+type tagsInput struct {
+	Tags []string `json:"tags"`
+}
+
+// addTagsEndpoint adds tags to a server, keeping those it has, so that clients
+// need not read the tags first and risk losing tags added in the meantime.
+func (a *App) addTagsEndpoint(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		respondWithError(w, req, http.StatusBadRequest, codeInvalidID, "Invalid server ID")
+		return
+	}
+	var in tagsInput
+	decoder := json.NewDecoder(req.Body)
+	if err := decoder.Decode(&in); err != nil {
+		respondWithPayloadError(w, req, err)
+		return
+	}
+	defer req.Body.Close()
+
+	tags, violations := validation.Tags(in.Tags)
+	if len(tags) == 0 {
+		violations = append(violations, validation.Violation{Field: "tags", Code: "required", Message: "At least one tag is required"})
+	}
+	if len(violations) > 0 {
+		respondWithViolations(w, req, violations)
+		return
+	}
+	s := servers.Server{ID: int64(id)}
+	if err := s.AddTags(req.Context(), a.DB, tags, validation.MaxTags); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			respondWithError(w, req, http.StatusNotFound, codeNotFound, "Server not found")
+		case servers.ErrTooManyTags:
+			respondWithViolations(w, req, []validation.Violation{validation.TooManyTags()})
+		default:
+			respondWithInternalError(w, req, "addTagsEndpoint - Error on AddTags", err)
+		}
 		return
 	}
+	// Read back the tags and timestamps
+	if err := s.GetServer(req.Context(), a.DB); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			respondWithError(w, req, http.StatusNotFound, codeNotFound, "Server not found")
+		default:
+			respondWithInternalError(w, req, "addTagsEndpoint - Error on GetServer", err)
+		}
+		return
+	}
+	audit(req, "modified", s)
+	a.publish(eventModified, s)
 	respondWithJSON(w, http.StatusOK, s)
 }
 
 func (a *App) deleteServerEndpoint(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid server ID")
+		respondWithError(w, req, http.StatusBadRequest, codeInvalidID, "Invalid server ID")
 		return
 	}
 	s := servers.Server{ID: int64(id)}
-	if _, err := s.DeleteServer(a.DB); err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+	res, err := s.DeleteServer(req.Context(), a.DB)
+	if err != nil {
+		respondWithInternalError(w, req, "deleteServerEndpoint - Error on DeleteServer", err)
 		return
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, req, http.StatusNotFound, codeNotFound, "Server not found")
+		return
+	}
+	audit(req, "deleted", s)
+	a.publish(eventDeleted, s)
 	respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
 
@@ -132,18 +314,27 @@ func (a *App) searchServersEndpoint(w http.ResponseWriter, req *http.Request, _
 	if start < 0 {
 		start = 0
 	}
+	sort := req.FormValue("sort")
+	if !servers.ValidSort(sort) {
+		sort = servers.DefaultSort
+	}
 
-	servers, err := servers.SearchServers(a.DB, start, count, name)
+	list, err := servers.SearchServers(req.Context(), a.DB, start, count, name, sort)
+	if err != nil {
+		respondWithInternalError(w, req, "searchServersEndpoint - Error on SearchServers", err)
+		return
+	}
+	total, err := servers.CountMatchingServers(req.Context(), a.DB, name)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithInternalError(w, req, "searchServersEndpoint - Error on CountMatchingServers", err)
 		return
 	}
-	respondWithJSON(w, http.StatusOK, servers)
+	w.Header().Set(totalCountHeader, strconv.FormatInt(total, 10))
+	respondWithJSON(w, http.StatusOK, list)
 }
 
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, map[string]string{"error": message})
-}
+// totalCountHeader gives the number of servers in all, of which a page is listed.
+const totalCountHeader = "X-Total-Count"
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, _ := json.Marshal(payload)
@@ -152,49 +343,219 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(response)
 }
 
-func basicAuth(h httprouter.Handle, requiredUser, requiredPassword string) httprouter.Handle {
+type contextKey int
+
+const identityContextKey contextKey = iota
+
+// actingUser returns the identity responsible for an authenticated request.
+func actingUser(req *http.Request) identity.Identity {
+	id, _ := req.Context().Value(identityContextKey).(identity.Identity)
+	return id
+}
+
+// audit records a change to the inventory and who made it.
+func audit(req *http.Request, action string, s servers.Server) {
+	id := actingUser(req)
+	slog.InfoContext(req.Context(), "Audit", "user", id.User, "role", id.Role,
+		"action", action, "server_id", s.ID, "server_name", s.Name, "server_status", s.Status, "server_tags", s.Tags)
+}
+
+// withIdentity establishes who an authenticated request is for. Requests from the
+// web client carry a signed end-user identity; anyone else is an API user with
+// full privileges.
+func (a *App) withIdentity(h httprouter.Handle) httprouter.Handle {
+
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		user, _, _ := req.BasicAuth()
+		id := identity.Identity{User: user, Role: identity.RoleAdmin}
+
+		if token := req.Header.Get(identity.Header); token != "" {
+			var err error
+			id, err = identity.Verify(a.IdentityKey, token, time.Now())
+			if err != nil {
+				slog.WarnContext(req.Context(), "withIdentity - Rejected identity", "api_user", user, "error", err)
+				respondWithError(w, req, http.StatusUnauthorized, codeInvalidIdentity, "Invalid identity token")
+				return
+			}
+		}
+
+		h(w, req.WithContext(context.WithValue(req.Context(), identityContextKey, id)), ps)
+	}
+}
+
+// requireRole only delegates to the given handle for identities with at least the specified role.
+func requireRole(role string, h httprouter.Handle) httprouter.Handle {
+
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		if id := actingUser(req); !id.HasRole(role) {
+			slog.WarnContext(req.Context(), "requireRole - Insufficient role", "user", id.User, "role", id.Role, "required", role)
+			respondWithError(w, req, http.StatusForbidden, codeForbidden, "Insufficient privileges")
+			return
+		}
+		h(w, req, ps)
+	}
+}
+
+// basicAuth only delegates to the given handle for the required user. Addresses
+// that keep failing to authenticate are locked out, as are addresses guessing
+// at an account that others keep failing to authenticate as; but an account's
+// failures never keep out the right password, or the web client could be
+// locked out by anyone who knows its user name.
+func (a *App) basicAuth(h httprouter.Handle, requiredUser, requiredPassword string) httprouter.Handle {
 
 	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		// Get the Basic Authentication credentials
 		user, password, hasAuth := req.BasicAuth()
 
-		if hasAuth && user == requiredUser && password == requiredPassword {
+		ipKey := "ip:" + middleware.ClientIP(req)
+		if wait := a.throttle.Locked(ipKey); wait > 0 {
+			slog.WarnContext(req.Context(), "basicAuth - Locked out", "user", user, "ip", middleware.ClientIP(req), "wait", wait.String())
+			respondWithThrottled(w, req, wait)
+			return
+		}
+
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(requiredUser)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(password), []byte(requiredPassword)) == 1
+		if hasAuth && userOK && passOK {
+			a.throttle.Succeed(ipKey)
 			// Delegate request to the given handle
 			h(w, req, ps)
 		} else {
+			if hasAuth {
+				// The password has been checked, so the failure counts against the account too
+				lockout := a.throttle.Fail(ipKey, "user:"+user)
+				a.throttle.Lock(lockout, ipKey)
+				slog.WarnContext(req.Context(), "Auth failure", "user", user, "ip", middleware.ClientIP(req), "lockout", lockout.String())
+			}
 			// Request Basic Authentication otherwise
 			w.Header().Set("WWW-Authenticate", "Basic realm=Restricted")
-			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			respondWithError(w, req, http.StatusUnauthorized, codeUnauthorized, "Valid credentials are required")
 		}
 	}
 }
 
+// rateLimit only delegates to the given handle while the acting user is within
+// their budget. Each end user of the web client has a budget of their own,
+// rather than sharing the web client's.
+func (a *App) rateLimit(h httprouter.Handle) httprouter.Handle {
+
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		id := actingUser(req)
+		key := "api-user:" + id.User
+		if req.Header.Get(identity.Header) != "" {
+			key = "end-user:" + id.User
+		}
+		if ok, wait := a.throttle.Allow(key); !ok {
+			slog.WarnContext(req.Context(), "rateLimit - Throttled", "user", id.User, "ip", middleware.ClientIP(req), "wait", wait.String())
+			respondWithThrottled(w, req, wait)
+			return
+		}
+		h(w, req, ps)
+	}
+}
+
 // Initialize sets up the database connection, router, and routes for the app
-func (a *App) Initialize(dbHost, dbPort, dbUser, dbPassword, dbName, authUser, authPassword string) {
+func (a *App) Initialize(cfg *config.Config) {
+
+	a.Config = cfg
 
 	// For SSL, specify '?tls=skip-verify'. For TLS, specify '?tls=true'.
-	connectionString := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?tls=skip-verify", dbUser, dbPassword, dbHost, dbPort, dbName)
+	// Timestamps are read as UTC time.Time values.
+	connectionString := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?tls=skip-verify&parseTime=true&time_zone=%%27%%2B00%%3A00%%27",
+		cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.Database)
 
 	var err error
 
 	a.DB, err = sql.Open("mysql", connectionString)
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("Error on sql.Open", "error", err)
+		os.Exit(1)
 	}
 
+	a.IdentityKey = []byte(cfg.IdentitySigningKey)
+
+	// Budgets are per acting user, so the web client's end users don't share one
+	a.throttle = throttle.New(authRate, authBurst)
+
+	// auth admits API users, and web client users with at least the specified role
+	auth := func(role string, h httprouter.Handle) httprouter.Handle {
+		return a.basicAuth(a.withIdentity(a.rateLimit(requireRole(role, h))), cfg.Auth.User, cfg.Auth.Password)
+	}
+
+	a.metrics = newMetrics(a.DB)
+	a.events = events.New()
+
 	a.Router = httprouter.New()
+	a.Router.NotFound = http.HandlerFunc(notFoundHandler)
+	a.Router.MethodNotAllowed = http.HandlerFunc(methodNotAllowedHandler)
+	a.Router.PanicHandler = panicHandler
+	r := routes{a.Router, a.metrics, &a.routes}
+
+	a.Router.Handler("GET", "/metrics", a.metrics.handler())
+	r.GET("/healthz", a.healthEndpoint)
+	r.GET("/readyz", a.readyEndpoint)
+	r.GET("/openapi.yaml", a.openAPIEndpoint)
+	r.GET("/docs", a.docsEndpoint)
+	a.Router.Handler("GET", "/docs/swagger-ui/*file", swaggerUIHandler())
+
+	r.GET("/v1/servers", a.getServersEndpoint)
+	r.POST("/v1/servers", auth(identity.RoleEditor, a.createServerEndpoint))
+	r.GET("/v1/servers/:id", a.getServerEndpoint)
+	r.PUT("/v1/servers/:id", auth(identity.RoleEditor, a.modifyServerEndpoint))
+	r.PATCH("/v1/servers/:id", auth(identity.RoleEditor, a.modifyServerEndpoint))
+	r.DELETE("/v1/servers/:id", auth(identity.RoleAdmin, a.deleteServerEndpoint))
+	r.POST("/v1/servers/:id/tags", auth(identity.RoleEditor, a.addTagsEndpoint))
+	r.POST("/v1/search/servers", a.searchServersEndpoint)
+	r.GET("/v1/events", a.eventsEndpoint)
+}
+
+// Run serves on the configured port until ctx is cancelled
+func (a *App) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", ":"+a.Config.Port)
+	if err != nil {
+		return err
+	}
+	slog.Info("Now serving servers ...", "port", a.Config.Port)
+	return a.Serve(ctx, ln)
+}
+
+// Serve serves TLS on the listener until ctx is cancelled, then waits for
+// in-flight requests to finish, for up to the shutdown grace period.
+func (a *App) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:      a.Router,
+		ReadTimeout:  a.Config.Timeouts.Read,
+		WriteTimeout: a.Config.Timeouts.Write,
+		IdleTimeout:  a.Config.Timeouts.Idle,
+	}
+	// End event streams, which would otherwise keep the server from shutting down
+	if a.events != nil {
+		srv.RegisterOnShutdown(a.events.Close)
+	}
 
-	a.Router.GET("/v1/servers", a.getServersEndpoint)
-	a.Router.POST("/v1/servers", basicAuth(a.createServerEndpoint, authUser, authPassword))
-	a.Router.GET("/v1/servers/:id", a.getServerEndpoint)
-	a.Router.PUT("/v1/servers/:id", basicAuth(a.modifyServerEndpoint, authUser, authPassword))
-	a.Router.PATCH("/v1/servers/:id", basicAuth(a.modifyServerEndpoint, authUser, authPassword))
-	a.Router.DELETE("/v1/servers/:id", basicAuth(a.deleteServerEndpoint, authUser, authPassword))
-	a.Router.POST("/v1/search/servers", a.searchServersEndpoint)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ServeTLS(ln, a.Config.TLS.Cert, a.Config.TLS.Key)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down ...")
+	grace := a.Config.Timeouts.ShutdownGrace
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("requests still in progress after %v: %v", grace, err)
+	}
+	return nil
 }
 
-// Run starts the app and serves on the specified port
-func (a *App) Run(port string) {
-	log.Print("Now serving servers ...")
-	log.Fatal(http.ListenAndServeTLS(":"+port, "../../certificates/REST-server.pem", "../../certificates/REST-server-private-key.pem", a.Router))
+// Close releases the database connections
+func (a *App) Close() error {
+	return a.DB.Close()
 }
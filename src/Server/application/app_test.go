@@ -0,0 +1,100 @@
+package application
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	// local packages
+	"admin-server/config"
+
+	// GitHub packages
+	"github.com/julienschmidt/httprouter"
+)
+
+// slowApp serves a single route, which waits until release is closed.
+func slowApp(t *testing.T, grace time.Duration) (*App, net.Listener, chan struct{}, chan struct{}) {
+	cfg := config.Default()
+	cfg.TLS.Cert = "../../../certificates/REST-server.pem"
+	cfg.TLS.Key = "../../../certificates/REST-server-private-key.pem"
+	cfg.Timeouts.ShutdownGrace = grace
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	a := &App{Config: &cfg, Router: httprouter.New()}
+	a.Router.GET("/slow", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, ln, started, release
+}
+
+func getSlow(ln net.Listener, codes chan int) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/slow")
+	if err != nil {
+		codes <- 0
+		return
+	}
+	resp.Body.Close()
+	codes <- resp.StatusCode
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	a, ln, started, release := slowApp(t, 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- a.Serve(ctx, ln) }()
+
+	codes := make(chan int, 1)
+	go getSlow(ln, codes)
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		t.Fatalf("Serve returned before the request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if code := <-codes; code != http.StatusOK {
+		t.Errorf("Expected in-flight request to complete with %d. Got %d", http.StatusOK, code)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown. Got '%v'", err)
+	}
+}
+
+func TestShutdownGracePeriod(t *testing.T) {
+	a, ln, started, release := slowApp(t, 100*time.Millisecond)
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- a.Serve(ctx, ln) }()
+
+	codes := make(chan int, 1)
+	go getSlow(ln, codes)
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("Expected an error when the grace period expires")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Serve did not return after the grace period")
+	}
+}
@@ -0,0 +1,58 @@
+package application
+
+import (
+	"embed"
+	"net/http"
+
+	// GitHub packages
+	"github.com/julienschmidt/httprouter"
+)
+
+// openAPI describes the REST API. Keep it up to date with the routes in
+// Initialize: the contract test checks that they match.
+//
+//go:embed openapi.yaml
+var openAPI []byte
+
+// swaggerUI holds the Swagger UI release used by the documentation page, so
+// that it needs no internet access. See swagger-ui/README.md.
+//
+//go:embed swagger-ui/swagger-ui-bundle.js swagger-ui/swagger-ui.css
+var swaggerUI embed.FS
+
+// docsPage shows the OpenAPI document with Swagger UI.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Sadmin REST API</title>
+    <link rel="stylesheet" href="/docs/swagger-ui/swagger-ui.css"/>
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="/docs/swagger-ui/swagger-ui-bundle.js"></script>
+<script>
+    window.onload = function () {
+        SwaggerUIBundle({url: "/openapi.yaml", dom_id: "#swagger-ui"});
+    };
+</script>
+</body>
+</html>
+`
+
+// openAPIEndpoint serves the OpenAPI document.
+func (a *App) openAPIEndpoint(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPI)
+}
+
+// swaggerUIHandler serves the Swagger UI files under /docs/swagger-ui/.
+func swaggerUIHandler() http.Handler {
+	return http.StripPrefix("/docs", http.FileServer(http.FS(swaggerUI)))
+}
+
+// docsEndpoint serves the interactive documentation.
+func (a *App) docsEndpoint(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsPage))
+}
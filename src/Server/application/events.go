@@ -0,0 +1,81 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	// local packages
+	"admin-server/servers"
+
+	// GitHub packages
+	"github.com/julienschmidt/httprouter"
+)
+
+// Types of event
+const (
+	eventCreated  = "server.created"
+	eventModified = "server.modified"
+	eventDeleted  = "server.deleted"
+	eventReset    = "reset"
+)
+
+// A comment is sent this often, so that proxies keep quiet streams open
+const keepAliveInterval = 15 * time.Second
+
+// How long clients wait before reconnecting, in milliseconds
+const reconnectDelay = 3000
+
+// publish tells everyone watching the inventory about a change to a server.
+func (a *App) publish(eventType string, s servers.Server) {
+	var data []byte
+	if eventType == eventDeleted {
+		data, _ = json.Marshal(map[string]int64{"id": s.ID})
+	} else {
+		data, _ = json.Marshal(s)
+	}
+	a.events.Publish(eventType, data)
+}
+
+func (a *App) eventsEndpoint(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	lastID, _ := strconv.ParseInt(req.Header.Get("Last-Event-ID"), 10, 64)
+	events, unsubscribe, complete := a.events.Subscribe(lastID)
+	defer unsubscribe()
+
+	// The stream outlasts the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay)
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventReset)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case e, ok := <-events:
+			// Closed on shutdown, or if this client fell behind
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package application
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	// local packages
+	"admin-server/config"
+	"admin-server/events"
+	"admin-server/servers"
+)
+
+// readEvent returns the fields of the next event in the stream, skipping
+// comments and the reconnection delay.
+func readEvent(t *testing.T, r *bufio.Reader) map[string]string {
+	fields := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if _, ok := fields["event"]; ok {
+				return fields
+			}
+			continue
+		}
+		if name, value, ok := strings.Cut(line, ": "); ok && name != "" {
+			fields[name] = value
+		}
+	}
+}
+
+func watch(t *testing.T, url, lastID string) *bufio.Reader {
+	req, _ := http.NewRequest("GET", url+"/v1/events", nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("Error on GET /v1/events: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream. Got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func TestEvents(t *testing.T) {
+	cfg := config.Default()
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+	// Closed once the streams have been, by their own cleanups
+	srv := httptest.NewServer(a.Router)
+	t.Cleanup(srv.Close)
+
+	stream := watch(t, srv.URL, "")
+	a.publish(eventCreated, servers.Server{ID: 7, Name: "web-01.example.com", Status: "active", Tags: []string{}})
+	a.publish(eventDeleted, servers.Server{ID: 7, Name: "web-01.example.com"})
+
+	e := readEvent(t, stream)
+	if e["id"] != "1" || e["event"] != eventCreated || !strings.Contains(e["data"], `"name":"web-01.example.com"`) {
+		t.Errorf("Expected the server created. Got %v", e)
+	}
+	e = readEvent(t, stream)
+	if e["id"] != "2" || e["event"] != eventDeleted || e["data"] != `{"id":7}` {
+		t.Errorf("Expected the server deleted. Got %v", e)
+	}
+
+	// Resuming after the first event
+	if e := readEvent(t, watch(t, srv.URL, "1")); e["id"] != "2" {
+		t.Errorf("Expected the missed event. Got %v", e)
+	}
+	// Resuming from before a restart
+	if e := readEvent(t, watch(t, srv.URL, "99")); e["event"] != eventReset {
+		t.Errorf("Expected a reset. Got %v", e)
+	}
+}
+
+func TestShutdownEndsEventStreams(t *testing.T) {
+	a, ln, _, _ := slowApp(t, 5*time.Second)
+	a.events = events.New()
+	a.Router.GET("/v1/events", a.eventsEndpoint)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- a.Serve(ctx, ln) }()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/v1/events")
+	if err != nil {
+		t.Fatalf("Error on GET /v1/events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	start := time.Now()
+	cancel()
+	if err := <-served; err != nil || time.Since(start) > time.Second {
+		t.Errorf("Expected the stream to end promptly on shutdown. Got %v after %v", err, time.Since(start))
+	}
+}
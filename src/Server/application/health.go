@@ -0,0 +1,96 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	// local packages
+	"admin-server/migrations"
+
+	// GitHub packages
+	"github.com/julienschmidt/httprouter"
+)
+
+// Delays between attempts to connect to the database at startup
+const firstRetryDelay = 500 * time.Millisecond
+const maxRetryDelay = 10 * time.Second
+
+// How long a readiness check may take
+const readyTimeout = 2 * time.Second
+
+// retry calls f until it succeeds, doubling the delay between attempts, and
+// gives up once another attempt would be made after the timeout.
+func retry(ctx context.Context, timeout time.Duration, what string, f func() error) error {
+	deadline := time.Now().Add(timeout)
+	delay := firstRetryDelay
+	for {
+		err := f()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("%s unavailable after %v: %v", what, timeout, err)
+		}
+		slog.Warn(what+" unavailable, retrying", "delay", delay.String(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// Connect waits for the database to become available, then brings its
+// schema up to date.
+func (a *App) Connect(ctx context.Context) error {
+	err := retry(ctx, a.Config.MySQL.ConnectTimeout, "Database", func() error {
+		return a.DB.PingContext(ctx)
+	})
+	if err != nil {
+		return err
+	}
+	return migrations.Apply(ctx, a.DB)
+}
+
+// healthEndpoint reports that the server is alive.
+func (a *App) healthEndpoint(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyEndpoint reports whether the server can handle requests: the database
+// is reachable and its schema is up to date.
+func (a *App) readyEndpoint(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	ctx, cancel := context.WithTimeout(req.Context(), readyTimeout)
+	defer cancel()
+
+	// Failures are logged rather than shown, as anyone may ask
+	checks := map[string]string{"database": "ok", "migrations": "ok"}
+	ready := true
+	if err := a.DB.PingContext(ctx); err != nil {
+		slog.ErrorContext(req.Context(), "readyEndpoint - Error on PingContext", "error", err)
+		checks["database"] = "unavailable"
+		checks["migrations"] = "unknown"
+		ready = false
+	} else if n, err := migrations.Pending(ctx, a.DB); err != nil {
+		slog.ErrorContext(req.Context(), "readyEndpoint - Error on migrations.Pending", "error", err)
+		checks["migrations"] = "unavailable"
+		ready = false
+	} else if n > 0 {
+		checks["migrations"] = fmt.Sprintf("%d pending", n)
+		ready = false
+	}
+
+	if !ready {
+		respondWithJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "unavailable", "checks": checks})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"status": "ready", "checks": checks})
+}
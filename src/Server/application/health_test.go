@@ -0,0 +1,70 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	// local packages
+	"admin-server/config"
+)
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	err := retry(context.Background(), time.Minute, "Test", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Expected success on the 3rd call. Got '%v' after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = retry(context.Background(), time.Second, "Test", func() error {
+		calls++
+		return errors.New("never")
+	})
+	if err == nil || calls != 2 {
+		t.Errorf("Expected to give up after 2 calls. Got '%v' after %d calls", err, calls)
+	}
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	// Nothing listens on port 1, so the database is unavailable
+	cfg := config.Default()
+	cfg.MySQL.Host = "127.0.0.1"
+	cfg.MySQL.Port = "1"
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+	} {
+		req, _ := http.NewRequest("GET", tc.path, nil)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != tc.code {
+			t.Errorf("%s - Expected response code %d. Got %d", tc.path, tc.code, rr.Code)
+		}
+	}
+
+	// The error is logged, not shown
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if body := rr.Body.String(); !strings.Contains(body, `"database":"unavailable"`) || strings.Contains(body, "127.0.0.1") {
+		t.Errorf("Expected only 'unavailable' for the database. Got %s", body)
+	}
+}
@@ -0,0 +1,95 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	// local packages
+	"admin-server/servers"
+	"admin-server/validation"
+
+	// GitHub packages
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics are the Prometheus metrics of an App, served on /metrics.
+type metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sadmin_http_requests_total",
+			Help: "HTTP requests handled, by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sadmin_http_request_duration_seconds",
+			Help:    "Time taken to handle HTTP requests, by route, method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "sadmin"),
+		inventoryCollector{db: db},
+	)
+	return m
+}
+
+// handler serves the metrics.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// observe records a request to the route.
+func (m *metrics) observe(method, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(route, method, code).Inc()
+	m.duration.WithLabelValues(route, method, code).Observe(elapsed.Seconds())
+}
+
+// inventoryCollector reports the servers in the inventory when scraped.
+type inventoryCollector struct {
+	db *sql.DB
+}
+
+var serversDesc = prometheus.NewDesc("sadmin_servers", "Servers in the inventory, by status.", []string{"status"}, nil)
+
+// Longest counting the servers may take, so that a slow database does not hold
+// up the scrape
+const collectTimeout = 5 * time.Second
+
+func (c inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serversDesc
+}
+
+func (c inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	counts, err := servers.CountServersByStatus(ctx, c.db)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(serversDesc, err)
+		return
+	}
+	// Statuses no server has are still reported, as zero
+	for _, status := range validation.Statuses {
+		if _, ok := counts[status]; !ok {
+			counts[status] = 0
+		}
+	}
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(serversDesc, prometheus.GaugeValue, float64(count), status)
+	}
+}
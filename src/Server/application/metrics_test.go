@@ -0,0 +1,48 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	// local packages
+	"admin-server/config"
+)
+
+func TestMetrics(t *testing.T) {
+	// Nothing listens on port 1, so the database is unavailable
+	cfg := config.Default()
+	cfg.MySQL.Host = "127.0.0.1"
+	cfg.MySQL.Port = "1"
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+
+	for _, path := range []string{"/healthz", "/healthz", "/readyz", "/metrics"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		a.Router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected response code %d. Got %d", http.StatusOK, rr.Code)
+	}
+
+	body := rr.Body.String()
+	for _, expected := range []string{
+		`sadmin_http_requests_total{code="200",method="GET",route="/healthz"} 2`,
+		`sadmin_http_requests_total{code="503",method="GET",route="/readyz"} 1`,
+		`sadmin_http_request_duration_seconds_count{code="200",method="GET",route="/healthz"} 2`,
+		`go_sql_open_connections{db_name="sadmin"}`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metric '%s'", expected)
+		}
+	}
+	if strings.Contains(body, `route="/metrics"`) {
+		t.Error("Scrapes should not be counted")
+	}
+}
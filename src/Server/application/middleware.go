@@ -0,0 +1,27 @@
+package application
+
+import (
+	// local packages
+	"admin-server/middleware"
+
+	// GitHub packages
+	"github.com/julienschmidt/httprouter"
+)
+
+// routes registers tracked handlers on the router.
+type routes struct {
+	router     *httprouter.Router
+	metrics    *metrics
+	registered *[]string // "METHOD path" of each route
+}
+
+func (r routes) handle(method, path string, h httprouter.Handle) {
+	r.router.Handle(method, path, middleware.Track(method, path, r.metrics.observe, h))
+	*r.registered = append(*r.registered, method+" "+path)
+}
+
+func (r routes) GET(path string, h httprouter.Handle)    { r.handle("GET", path, h) }
+func (r routes) POST(path string, h httprouter.Handle)   { r.handle("POST", path, h) }
+func (r routes) PUT(path string, h httprouter.Handle)    { r.handle("PUT", path, h) }
+func (r routes) PATCH(path string, h httprouter.Handle)  { r.handle("PATCH", path, h) }
+func (r routes) DELETE(path string, h httprouter.Handle) { r.handle("DELETE", path, h) }
@@ -0,0 +1,34 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	// local packages
+	"admin-server/config"
+	"admin-server/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	cfg := config.Default()
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	req.Header.Set(logging.Header, "from-web-client")
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if id := rr.Header().Get(logging.Header); id != "from-web-client" {
+		t.Errorf("Expected the request ID to be passed back. Got '%s'", id)
+	}
+
+	req, _ = http.NewRequest("GET", "/healthz", nil)
+	req.Header.Set(logging.Header, "not\tvalid")
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if id := rr.Header().Get(logging.Header); !logging.ValidRequestID(id) {
+		t.Errorf("Expected a new request ID. Got '%s'", id)
+	}
+}
@@ -0,0 +1,153 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	// local packages
+	"admin-server/config"
+	"admin-server/contract"
+	"admin-server/servers"
+)
+
+// The contract tests check the routes and responses of the REST server against
+// openapi.yaml. The integration tests check the responses with a database.
+
+func loadSpec(t *testing.T) *contract.Spec {
+	spec, err := contract.Load(openAPI)
+	if err != nil {
+		t.Fatalf("Error parsing openapi.yaml: %v", err)
+	}
+	return spec
+}
+
+func TestRoutesMatchSpec(t *testing.T) {
+	spec := loadSpec(t)
+	cfg := config.Default()
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+
+	ops := spec.Operations()
+	for _, route := range a.routes {
+		fields := strings.Fields(route)
+		if _, ok := ops[fields[0]+" "+contract.Path(fields[1])]; !ok {
+			t.Errorf("%s is not in the spec", route)
+		}
+	}
+
+	var names []string
+	for name := range ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields := strings.Fields(name)
+		path := strings.Replace(fields[1], "{id}", "1", 1)
+		if h, _, _ := a.Router.Lookup(fields[0], path); h == nil {
+			t.Errorf("%s is in the spec but not routed", name)
+		}
+		if _, ok := ops[name]["operationId"]; !ok {
+			t.Errorf("%s has no operationId", name)
+		}
+	}
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	spec := loadSpec(t)
+
+	// Nothing listens on port 1, so the database is unavailable
+	cfg := config.Default()
+	cfg.MySQL.Host = "127.0.0.1"
+	cfg.MySQL.Port = "1"
+	cfg.Auth.User, cfg.Auth.Password = "api", "secret"
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+
+	for _, tc := range []struct {
+		op, path, body string
+		auth           bool
+	}{
+		{"GET /healthz", "/healthz", "", false},
+		{"GET /readyz", "/readyz", "", false},
+		{"GET /openapi.yaml", "/openapi.yaml", "", false},
+		{"GET /docs", "/docs", "", false},
+		{"GET /metrics", "/metrics", "", false},
+		{"GET /v1/servers", "/v1/servers", "", false},
+		{"GET /v1/servers/{id}", "/v1/servers/x", "", false},
+		{"GET /v1/servers/{id}", "/v1/servers/1", "", false},
+		{"POST /v1/servers", "/v1/servers", `{"name":"a.example.com"}`, false},
+		{"POST /v1/servers", "/v1/servers", `{"name":42}`, true},
+		{"POST /v1/servers", "/v1/servers", `{"name":"-"}`, true},
+		{"POST /v1/servers", "/v1/servers", `{"name":"a.example.com"}`, true},
+		{"PUT /v1/servers/{id}", "/v1/servers/1", `{"name":""}`, true},
+		{"PATCH /v1/servers/{id}", "/v1/servers/x", `{"name":"a.example.com"}`, true},
+		{"POST /v1/servers/{id}/tags", "/v1/servers/x/tags", `{"tags":["db"]}`, true},
+		{"POST /v1/servers/{id}/tags", "/v1/servers/1/tags", `{"tags":[]}`, true},
+		{"DELETE /v1/servers/{id}", "/v1/servers/1", "", false},
+		{"DELETE /v1/servers/{id}", "/v1/servers/1", "", true},
+	} {
+		method := strings.Fields(tc.op)[0]
+		req, _ := http.NewRequest(method, tc.path, bytes.NewBufferString(tc.body))
+		if tc.auth {
+			req.SetBasicAuth("api", "secret")
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		for _, err := range spec.CheckResponse(tc.op, rr) {
+			t.Errorf("%s %s - %s", method, tc.path, err)
+		}
+	}
+}
+
+func TestSchemasMatchTypes(t *testing.T) {
+	spec := loadSpec(t)
+
+	for _, tc := range []struct {
+		schema string
+		value  interface{}
+	}{
+		{"Server", servers.Server{ID: 7, Name: "web-01.example.com", Status: "active", Tags: []string{"db"},
+			CreatedAt: time.Now(), UpdatedAt: time.Now()}},
+		{"ServerList", []servers.Server{{ID: 7, Name: "web-01.example.com", Status: "maintenance", Tags: []string{}}}},
+		{"Problem", problem{Type: problemTypePrefix + codeDuplicateName, Title: "Conflict", Status: http.StatusConflict,
+			Detail: "A server with this name already exists", Instance: "/v1/servers", Code: codeDuplicateName,
+			RequestID: "abc", Errors: []fieldError{{"name", "duplicate", "in use"}}}},
+	} {
+		b, _ := json.Marshal(tc.value)
+		var v interface{}
+		json.Unmarshal(b, &v)
+		for _, err := range spec.ValidateSchema(tc.schema, v) {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDocsAssets(t *testing.T) {
+	cfg := config.Default()
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+
+	for path, contentType := range map[string]string{
+		"/docs/swagger-ui/swagger-ui-bundle.js": "text/javascript",
+		"/docs/swagger-ui/swagger-ui.css":       "text/css",
+	} {
+		if !strings.Contains(docsPage, `"`+path+`"`) {
+			t.Errorf("%s is not used by the documentation page", path)
+		}
+		req, _ := http.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), contentType) {
+			t.Errorf("%s - Expected %s. Got %d %s", path, contentType, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	// local packages
+	"admin-server/logging"
+	"admin-server/servers"
+	"admin-server/throttle"
+	"admin-server/validation"
+
+	// GitHub packages
+	"github.com/go-sql-driver/mysql"
+)
+
+// Error codes are part of the API: clients may rely on them, so never change one.
+const (
+	codeInvalidID        = "invalid_id"
+	codeInvalidPayload   = "invalid_payload"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeDuplicateName    = "duplicate_name"
+	codeValidationFailed = "validation_failed"
+	codeUnauthorized     = "unauthorized"
+	codeInvalidIdentity  = "invalid_identity"
+	codeForbidden        = "forbidden"
+	codeRateLimited      = "rate_limited"
+	codeInternal         = "internal_error"
+)
+
+// problemTypePrefix is prefixed to an error code to give the problem type.
+const problemTypePrefix = "urn:sadmin:problem:"
+
+const problemContentType = "application/problem+json"
+
+// MySQL error number for a duplicate key
+const mysqlDuplicateEntry = 1062
+
+// problem is an RFC 7807 problem details response.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError describes what is wrong with one field of a request.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// respondWithError responds with a problem for the request, with a stable
+// error code and a detail message safe to show to the caller.
+func respondWithError(w http.ResponseWriter, req *http.Request, status int, code string, detail string, errs ...fieldError) {
+	p := problem{
+		Type:      problemTypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  req.URL.Path,
+		Code:      code,
+		RequestID: logging.RequestID(req.Context()),
+		Errors:    errs,
+	}
+	response, _ := json.Marshal(p)
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// respondWithInternalError logs the cause of an internal error, which may
+// contain database details, and responds without it.
+func respondWithInternalError(w http.ResponseWriter, req *http.Request, what string, err error) {
+	slog.ErrorContext(req.Context(), what, "error", err)
+	respondWithError(w, req, http.StatusInternalServerError, codeInternal,
+		"The request could not be completed, please quote the request ID if reporting this.")
+}
+
+// isDuplicate reports whether err is due to a duplicate key.
+func isDuplicate(err error) bool {
+	var merr *mysql.MySQLError
+	return errors.As(err, &merr) && merr.Number == mysqlDuplicateEntry
+}
+
+// respondWithDuplicate responds to an attempt to reuse the name of another server.
+func respondWithDuplicate(w http.ResponseWriter, req *http.Request, s servers.Server) {
+	respondWithError(w, req, http.StatusConflict, codeDuplicateName, "A server with this name already exists",
+		fieldError{"name", "duplicate", fmt.Sprintf("'%s' is already in use", s.Name)})
+}
+
+// respondWithViolations responds to a request which breaks the validation rules,
+// listing every violation.
+func respondWithViolations(w http.ResponseWriter, req *http.Request, violations []validation.Violation) {
+	errs := make([]fieldError, len(violations))
+	for i, v := range violations {
+		errs[i] = fieldError{v.Field, v.Code, v.Message}
+	}
+	respondWithError(w, req, http.StatusUnprocessableEntity, codeValidationFailed, "The server is not valid", errs...)
+}
+
+// respondWithThrottled responds to a request which must wait, saying for how long.
+func respondWithThrottled(w http.ResponseWriter, req *http.Request, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(throttle.RetryAfter(wait)))
+	respondWithError(w, req, http.StatusTooManyRequests, codeRateLimited, "Too many requests, please try again later")
+}
+
+// respondWithPayloadError responds to a request body which could not be decoded,
+// identifying the offending field where possible.
+func respondWithPayloadError(w http.ResponseWriter, req *http.Request, err error) {
+	var terr *json.UnmarshalTypeError
+	if errors.As(err, &terr) && terr.Field != "" {
+		respondWithError(w, req, http.StatusBadRequest, codeInvalidPayload, "Invalid request payload",
+			fieldError{terr.Field, "invalid_type", fmt.Sprintf("%s must be a %s", terr.Field, jsonType(terr.Type.Kind()))})
+		return
+	}
+	respondWithError(w, req, http.StatusBadRequest, codeInvalidPayload, "Invalid request payload")
+}
+
+// jsonType names a Go kind as its JSON type.
+func jsonType(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "number"
+	}
+}
+
+// notFoundHandler responds to requests for unknown routes.
+func notFoundHandler(w http.ResponseWriter, req *http.Request) {
+	respondWithError(w, req, http.StatusNotFound, codeNotFound, "No such resource")
+}
+
+// methodNotAllowedHandler responds to requests using the wrong method for a route.
+func methodNotAllowedHandler(w http.ResponseWriter, req *http.Request) {
+	respondWithError(w, req, http.StatusMethodNotAllowed, codeMethodNotAllowed,
+		req.Method+" is not supported for this resource")
+}
+
+// panicHandler responds to a request whose handler panicked.
+func panicHandler(w http.ResponseWriter, req *http.Request, rcv interface{}) {
+	respondWithInternalError(w, req, "Panic", fmt.Errorf("%v", rcv))
+}
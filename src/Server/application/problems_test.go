@@ -0,0 +1,73 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	// local packages
+	"admin-server/config"
+	"admin-server/logging"
+)
+
+func TestProblems(t *testing.T) {
+	// Nothing listens on port 1, so the database is unavailable
+	cfg := config.Default()
+	cfg.MySQL.Host = "127.0.0.1"
+	cfg.MySQL.Port = "1"
+	cfg.Auth.User, cfg.Auth.Password = "api", "secret"
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+
+	for _, tc := range []struct {
+		method, path, body string
+		auth               bool
+		status             int
+		code, field        string
+	}{
+		{"GET", "/v1/servers/x", "", false, http.StatusBadRequest, codeInvalidID, ""},
+		{"GET", "/v1/servers/1", "", false, http.StatusInternalServerError, codeInternal, ""},
+		{"GET", "/v1/nothing", "", false, http.StatusNotFound, codeNotFound, ""},
+		{"POST", "/v1/servers", `{"name":"a.b.c"}`, false, http.StatusUnauthorized, codeUnauthorized, ""},
+		{"POST", "/v1/servers", `{"name":`, true, http.StatusBadRequest, codeInvalidPayload, ""},
+		{"POST", "/v1/servers", `{"name":42}`, true, http.StatusBadRequest, codeInvalidPayload, "name"},
+		{"POST", "/v1/servers", `{"name":"localhost"}`, true, http.StatusUnprocessableEntity, codeValidationFailed, "name"},
+		{"PUT", "/v1/servers/1", `{"name":""}`, true, http.StatusUnprocessableEntity, codeValidationFailed, "name"},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set(logging.Header, "problem-test")
+		if tc.auth {
+			req.SetBasicAuth("api", "secret")
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+
+		if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+			t.Errorf("%s %s - Expected Content-Type %s. Got '%s'", tc.method, tc.path, problemContentType, ct)
+		}
+		var p problem
+		if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s %s - Expected a problem. Got %s", tc.method, tc.path, rr.Body.String())
+		}
+		if p.Status != tc.status || rr.Code != tc.status || p.Code != tc.code || p.Type != problemTypePrefix+tc.code {
+			t.Errorf("%s %s - Expected %d %s. Got %d %s", tc.method, tc.path, tc.status, tc.code, rr.Code, rr.Body.String())
+		}
+		if tc.field != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tc.field) {
+			t.Errorf("%s %s - Expected an error for field '%s'. Got %v", tc.method, tc.path, tc.field, p.Errors)
+		}
+		if p.Instance != tc.path {
+			t.Errorf("%s %s - Expected instance '%s'. Got '%s'", tc.method, tc.path, tc.path, p.Instance)
+		}
+		// The request ID is only known to routed requests
+		if p.RequestID != "problem-test" && p.Code != codeNotFound {
+			t.Errorf("%s %s - Expected the request ID. Got '%s'", tc.method, tc.path, p.RequestID)
+		}
+		if strings.Contains(p.Detail, "127.0.0.1") || strings.Contains(p.Detail, "dial") {
+			t.Errorf("%s %s - Expected no database details. Got '%s'", tc.method, tc.path, p.Detail)
+		}
+	}
+}
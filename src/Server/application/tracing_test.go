@@ -0,0 +1,54 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	// local packages
+	"admin-server/config"
+	"admin-server/tracing"
+
+	// GitHub packages
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	if _, err := tracing.Setup(context.Background(), "test", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing listens on port 1, so the query fails
+	cfg := config.Default()
+	cfg.MySQL.Host = "127.0.0.1"
+	cfg.MySQL.Port = "1"
+	a := App{}
+	a.Initialize(&cfg)
+	defer a.Close()
+
+	req, _ := http.NewRequest("GET", "/v1/servers/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	a.Router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected a query span and a request span. Got %d spans", len(spans))
+	}
+	query, request := spans[0], spans[1]
+	if request.Name != "GET /v1/servers/:id" || request.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the request span to continue the caller's trace. Got '%s' with parent %s", request.Name, request.Parent.SpanID())
+	}
+	if query.Name != "SELECT servers" || query.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Errorf("Expected the query span to be a child of the request span. Got '%s'", query.Name)
+	}
+	if query.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the caller's trace ID. Got %s", query.SpanContext.TraceID())
+	}
+}
@@ -0,0 +1,220 @@
+// Package config is the configuration of the REST server.
+//
+// Each setting is taken from, in increasing order of precedence: its default,
+// a YAML config file (named by the -config flag or CONFIG_FILE), its environment
+// variable, and its command-line flag, as described in the settings package.
+// The sections common to the REST server and the web client are here too.
+package config
+
+import (
+	"io"
+	"io/ioutil"
+	"time"
+
+	// local import
+	"admin-server/logging"
+	"admin-server/settings"
+	"admin-server/validation"
+)
+
+// TLS is the server's certificate.
+type TLS struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+// MySQL is the database connection.
+type MySQL struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+
+	// ConnectTimeout is how long to keep retrying the connection at startup.
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+// Auth is the API user, which may make changes.
+type Auth struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// Timeouts limit how long connections may take, and how long in-flight
+// requests are given to finish on shutdown.
+type Timeouts struct {
+	Read          time.Duration `yaml:"read"`
+	Write         time.Duration `yaml:"write"`
+	Idle          time.Duration `yaml:"idle"`
+	ShutdownGrace time.Duration `yaml:"shutdown_grace_period"`
+}
+
+// Log is how log lines are written.
+type Log struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// Tracing is where OpenTelemetry spans are sent.
+type Tracing struct {
+	Endpoint string `yaml:"endpoint"`
+}
+
+// Config is the configuration of the REST server.
+type Config struct {
+	Port               string           `yaml:"port"`
+	TLS                TLS              `yaml:"tls"`
+	Timeouts           Timeouts         `yaml:"timeouts"`
+	Log                Log              `yaml:"log"`
+	Tracing            Tracing          `yaml:"tracing"`
+	MySQL              MySQL            `yaml:"mysql"`
+	Auth               Auth             `yaml:"auth"`
+	IdentitySigningKey string           `yaml:"identity_signing_key"`
+	ServerNames        validation.Rules `yaml:"server_names"`
+}
+
+// Default returns the default configuration.
+func Default() Config {
+	return Config{
+		Port: "8100",
+		TLS: TLS{
+			Cert: "../../certificates/REST-server.pem",
+			Key:  "../../certificates/REST-server-private-key.pem",
+		},
+		Timeouts: Timeouts{
+			Read:          15 * time.Second,
+			Write:         30 * time.Second,
+			Idle:          2 * time.Minute,
+			ShutdownGrace: 20 * time.Second,
+		},
+		Log:         Log{Level: "info", Format: "json"},
+		MySQL:       MySQL{Port: "3306", ConnectTimeout: time.Minute},
+		ServerNames: validation.DefaultRules(),
+	}
+}
+
+// Settings are the TLS settings.
+func (t *TLS) Settings() []settings.Setting {
+	return []settings.Setting{
+		settings.Flag("tls-cert", "TLS_CERT", settings.String(&t.Cert), "TLS certificate file"),
+		settings.Flag("tls-key", "TLS_KEY", settings.String(&t.Key), "TLS private key file"),
+	}
+}
+
+// Validate checks that the certificate and its key exist.
+func (t *TLS) Validate(v *settings.Validator) {
+	v.File("tls.cert (TLS_CERT)", t.Cert)
+	v.File("tls.key (TLS_KEY)", t.Key)
+}
+
+// Settings are the timeout settings.
+func (t *Timeouts) Settings() []settings.Setting {
+	return []settings.Setting{
+		settings.Flag("read-timeout", "READ_TIMEOUT", settings.Duration(&t.Read), "maximum time to read a request"),
+		settings.Flag("write-timeout", "WRITE_TIMEOUT", settings.Duration(&t.Write), "maximum time to write a response"),
+		settings.Flag("idle-timeout", "IDLE_TIMEOUT", settings.Duration(&t.Idle), "maximum time an idle connection is kept open"),
+		settings.Flag("shutdown-grace-period", "SHUTDOWN_GRACE_PERIOD", settings.Duration(&t.ShutdownGrace), "time allowed for in-flight requests to finish on shutdown"),
+	}
+}
+
+// Validate checks that the timeouts are positive.
+func (t *Timeouts) Validate(v *settings.Validator) {
+	v.Positive("timeouts.read (READ_TIMEOUT)", t.Read)
+	v.Positive("timeouts.write (WRITE_TIMEOUT)", t.Write)
+	v.Positive("timeouts.idle (IDLE_TIMEOUT)", t.Idle)
+	v.Positive("timeouts.shutdown_grace_period (SHUTDOWN_GRACE_PERIOD)", t.ShutdownGrace)
+}
+
+// Settings are the log settings.
+func (l *Log) Settings() []settings.Setting {
+	return []settings.Setting{
+		settings.Flag("log-level", "LOG_LEVEL", settings.String(&l.Level), "minimum level logged: debug, info, warn or error"),
+		settings.Flag("log-format", "LOG_FORMAT", settings.String(&l.Format), "log format: json or text (logfmt)"),
+	}
+}
+
+// Validate checks that a logger can be made with the level and format.
+func (l *Log) Validate(v *settings.Validator) {
+	if _, err := logging.New(ioutil.Discard, l.Level, l.Format); err != nil {
+		v.Errorf("log (LOG_LEVEL, LOG_FORMAT): %v", err)
+	}
+}
+
+// Settings are the tracing settings.
+func (t *Tracing) Settings() []settings.Setting {
+	return []settings.Setting{
+		settings.Flag("tracing-endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", settings.String(&t.Endpoint), "OTLP/HTTP endpoint to send traces to (blank for none)"),
+	}
+}
+
+// Validate checks the endpoint, if there is one.
+func (t *Tracing) Validate(v *settings.Validator) {
+	if t.Endpoint != "" {
+		v.URL("tracing.endpoint (OTEL_EXPORTER_OTLP_ENDPOINT)", t.Endpoint, "http", "https")
+	}
+}
+
+// Settings are the settings of the REST server.
+func (c *Config) Settings() []settings.Setting {
+	var s []settings.Setting
+	s = append(s, settings.Flag("port", "PORT", settings.String(&c.Port), "port to serve on"))
+	s = append(s, c.TLS.Settings()...)
+	s = append(s, c.Timeouts.Settings()...)
+	s = append(s, c.Log.Settings()...)
+	s = append(s, c.Tracing.Settings()...)
+	return append(s, []settings.Setting{
+		settings.Flag("mysql-host", "MYSQL_HOST", settings.String(&c.MySQL.Host), "MySQL host"),
+		settings.Flag("mysql-port", "MYSQL_PORT", settings.String(&c.MySQL.Port), "MySQL port"),
+		settings.Flag("mysql-user", "MYSQL_USER", settings.String(&c.MySQL.User), "MySQL user"),
+		settings.Secret("MYSQL_PASSWORD", settings.String(&c.MySQL.Password)),
+		settings.Flag("mysql-db", "MYSQL_DB", settings.String(&c.MySQL.Database), "MySQL database"),
+		settings.Flag("mysql-connect-timeout", "MYSQL_CONNECT_TIMEOUT", settings.Duration(&c.MySQL.ConnectTimeout), "how long to retry connecting to MySQL at startup"),
+		settings.Flag("auth-user", "AUTH_USER", settings.String(&c.Auth.User), "API user allowed to make changes"),
+		settings.Secret("AUTH_PASSWORD", settings.String(&c.Auth.Password)),
+		settings.Secret("IDENTITY_SIGNING_KEY", settings.String(&c.IdentitySigningKey)),
+		settings.Flag("server-name-min-labels", "SERVER_NAME_MIN_LABELS", settings.Int(&c.ServerNames.MinLabels), "fewest dot-separated labels a server name may have"),
+		settings.Flag("server-name-max-length", "SERVER_NAME_MAX_LENGTH", settings.Int(&c.ServerNames.MaxLength), "longest a server name may be"),
+		settings.Flag("server-name-suffixes", "SERVER_NAME_SUFFIXES", settings.List(&c.ServerNames.AllowedSuffixes), "comma-separated domains server names must be in (blank for any)"),
+	}...)
+}
+
+// Load returns the configuration for the command-line arguments (excluding
+// the program name), and the environment.
+func Load(name string, args []string) (*Config, error) {
+	c := Default()
+	if err := settings.Load(name, args, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks that the configuration is complete and consistent.
+func (c *Config) Validate() error {
+	var v settings.Validator
+
+	v.Port("port (PORT)", c.Port)
+	c.TLS.Validate(&v)
+	c.Timeouts.Validate(&v)
+	c.Log.Validate(&v)
+	c.Tracing.Validate(&v)
+	v.Required("mysql.host (MYSQL_HOST)", c.MySQL.Host)
+	v.Port("mysql.port (MYSQL_PORT)", c.MySQL.Port)
+	v.Required("mysql.user (MYSQL_USER)", c.MySQL.User)
+	v.Required("mysql.database (MYSQL_DB)", c.MySQL.Database)
+	v.Positive("mysql.connect_timeout (MYSQL_CONNECT_TIMEOUT)", c.MySQL.ConnectTimeout)
+	v.Required("auth.user (AUTH_USER)", c.Auth.User)
+	v.Required("auth.password (AUTH_PASSWORD)", c.Auth.Password)
+	if k := c.IdentitySigningKey; k != "" && len(k) < 32 {
+		v.Errorf("identity_signing_key (IDENTITY_SIGNING_KEY) must be at least 32 characters")
+	}
+	for _, e := range c.ServerNames.Validate() {
+		v.Errorf("server_names.%s", e)
+	}
+	return v.Err()
+}
+
+// Print writes the configuration as YAML, with any secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	return settings.Print(w, c)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, contents string, mode os.FileMode) string {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := ioutil.WriteFile(path, []byte(contents), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPrecedence(t *testing.T) {
+	path := writeConfig(t, "port: \"9000\"\nmysql:\n  host: file-host\n  user: file-user\n", 0644)
+	t.Setenv("MYSQL_HOST", "env-host")
+	t.Setenv("SHUTDOWN_GRACE_PERIOD", "5s")
+	t.Setenv("SERVER_NAME_SUFFIXES", "example.com, example.org")
+
+	c, err := Load("test", []string{"-config", path, "-mysql-user", "flag-user"})
+	if err != nil {
+		t.Fatalf("Error on Load: %v", err)
+	}
+	if c.Port != "9000" {
+		t.Errorf("Expected port from file. Got '%s'", c.Port)
+	}
+	if c.MySQL.Host != "env-host" {
+		t.Errorf("Expected host from environment. Got '%s'", c.MySQL.Host)
+	}
+	if c.MySQL.User != "flag-user" {
+		t.Errorf("Expected user from flag. Got '%s'", c.MySQL.User)
+	}
+	if c.MySQL.Port != "3306" {
+		t.Errorf("Expected default MySQL port. Got '%s'", c.MySQL.Port)
+	}
+	if c.Timeouts.ShutdownGrace != 5*time.Second {
+		t.Errorf("Expected grace period from environment. Got %v", c.Timeouts.ShutdownGrace)
+	}
+	if s := c.ServerNames.AllowedSuffixes; len(s) != 2 || s[1] != "example.org" {
+		t.Errorf("Expected allowed suffixes from environment. Got %v", s)
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	if _, err := Load("test", []string{"-config", writeConfig(t, "prot: 9000\n", 0644)}); err == nil {
+		t.Error("Unknown setting accepted!")
+	}
+	if _, err := Load("test", []string{"-config", writeConfig(t, "auth:\n  password: secret\n", 0644)}); err == nil {
+		t.Error("World-readable secrets accepted!")
+	}
+	if _, err := Load("test", []string{"-config", writeConfig(t, "auth:\n  password: secret\n", 0600)}); err != nil {
+		t.Errorf("Private secrets refused: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	c.Port = "http"
+	c.TLS.Cert = "/nonexistent/cert.pem"
+	c.IdentitySigningKey = "short"
+	c.Timeouts.ShutdownGrace = 0
+	c.ServerNames.MinLabels = 0
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Invalid configuration passed!")
+	}
+	for _, expected := range []string{"port (PORT)", "tls.cert (TLS_CERT)", "mysql.host (MYSQL_HOST)", "identity_signing_key",
+		"timeouts.shutdown_grace_period", "server_names.min_labels"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error for %s. Got:\n%v", expected, err)
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	c := Default()
+	c.Auth.User = "remote_user"
+	c.Auth.Password = "remotepass"
+
+	var b bytes.Buffer
+	if err := c.Print(&b); err != nil {
+		t.Fatalf("Error on Print: %v", err)
+	}
+	if strings.Contains(b.String(), "remotepass") || !strings.Contains(b.String(), "REDACTED") {
+		t.Errorf("Expected password to be redacted. Got:\n%s", b.String())
+	}
+	if c.Auth.Password != "remotepass" {
+		t.Error("Print changed the configuration!")
+	}
+}
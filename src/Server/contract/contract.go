@@ -0,0 +1,188 @@
+// Package contract checks requests and responses of the REST server against
+// its OpenAPI document, with just enough of JSON Schema for the schemas it
+// uses. It is shared by the unit tests and the integration tests.
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	// GitHub packages
+	"gopkg.in/yaml.v3"
+)
+
+type object = map[string]interface{}
+
+// A Spec is a parsed OpenAPI document.
+type Spec struct {
+	doc object
+}
+
+// Load parses an OpenAPI document.
+func Load(b []byte) (*Spec, error) {
+	var doc object
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return &Spec{doc: doc}, nil
+}
+
+// resolve follows a local $ref, if there is one.
+func (s *Spec) resolve(node object) object {
+	ref, ok := node["$ref"].(string)
+	if !ok {
+		return node
+	}
+	doc := s.doc
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		node, _ = doc[part].(object)
+		doc = node
+	}
+	return node
+}
+
+// Operations returns the operations in the spec, by "METHOD path".
+func (s *Spec) Operations() map[string]map[string]interface{} {
+	ops := map[string]object{}
+	for path, item := range s.doc["paths"].(object) {
+		for method, op := range item.(object) {
+			if method != "parameters" {
+				ops[strings.ToUpper(method)+" "+path] = op.(object)
+			}
+		}
+	}
+	return ops
+}
+
+// Path converts a router path to an OpenAPI one, /v1/servers/:id to /v1/servers/{id}.
+func Path(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ValidateSchema returns the ways in which a value, decoded from JSON, does
+// not match the named schema in components.
+func (s *Spec) ValidateSchema(name string, v interface{}) []string {
+	schemas := s.doc["components"].(object)["schemas"].(object)
+	schema, ok := schemas[name].(object)
+	if !ok {
+		return []string{fmt.Sprintf("%s: no such schema", name)}
+	}
+	return s.validate(schema, v, name)
+}
+
+// validate returns the ways in which the value does not match the schema.
+func (s *Spec) validate(schema object, v interface{}, at string) []string {
+	schema = s.resolve(schema)
+	var errs []string
+	wrongType := func() []string {
+		return []string{fmt.Sprintf("%s: expected %v. Got %T", at, schema["type"], v)}
+	}
+
+	switch schema["type"] {
+	case "object":
+		m, ok := v.(object)
+		if !ok {
+			return wrongType()
+		}
+		for _, r := range asList(schema["required"]) {
+			if _, ok := m[r.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing %s", at, r))
+			}
+		}
+		properties, _ := schema["properties"].(object)
+		for name, value := range m {
+			property, ok := properties[name].(object)
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s: unexpected %s", at, name))
+				continue
+			}
+			errs = append(errs, s.validate(property, value, at+"."+name)...)
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return wrongType()
+		}
+		for i, item := range a {
+			errs = append(errs, s.validate(schema["items"].(object), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return wrongType()
+		}
+	case "integer":
+		if f, ok := v.(float64); !ok || f != math.Trunc(f) {
+			return wrongType()
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return wrongType()
+		}
+	}
+
+	if enum := asList(schema["enum"]); len(enum) > 0 {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", at, v, enum))
+		}
+	}
+	return errs
+}
+
+func asList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// CheckResponse returns the ways in which a response to the operation, named
+// "METHOD path" as in Operations, does not match the spec.
+func (s *Spec) CheckResponse(name string, rr *httptest.ResponseRecorder) []string {
+	op, ok := s.Operations()[name]
+	if !ok {
+		return []string{fmt.Sprintf("%s is not in the spec", name)}
+	}
+	response, ok := op["responses"].(object)[strconv.Itoa(rr.Code)].(object)
+	if !ok {
+		return []string{fmt.Sprintf("Response %d is not in the spec", rr.Code)}
+	}
+	response = s.resolve(response)
+
+	content, _ := response["content"].(object)
+	if len(content) == 0 {
+		if rr.Body.Len() > 0 {
+			return []string{fmt.Sprintf("Response %d should have no content", rr.Code)}
+		}
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	media, ok := content[mediaType].(object)
+	if !ok {
+		return []string{fmt.Sprintf("Content-Type '%s' of response %d is not in the spec", mediaType, rr.Code)}
+	}
+	if !strings.HasSuffix(mediaType, "json") {
+		return nil
+	}
+	var body interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		return []string{fmt.Sprintf("Invalid JSON response %d: %v", rr.Code, err)}
+	}
+	var errs []string
+	for _, err := range s.validate(media["schema"].(object), body, "response") {
+		errs = append(errs, fmt.Sprintf("%d %s", rr.Code, err))
+	}
+	return errs
+}
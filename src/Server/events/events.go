@@ -0,0 +1,110 @@
+// Package events tells everyone watching the inventory about each change to
+// it, as it happens.
+package events
+
+import (
+	"sync"
+)
+
+// Kept is how many recent events are kept, for subscribers that reconnect
+// after missing some.
+const Kept = 256
+
+// Buffered is how many events a subscriber may fall behind by before it is
+// dropped; it may then reconnect and catch up from the events kept.
+const Buffered = 64
+
+// An Event is one change to the inventory.
+type Event struct {
+	ID   int64  // increasing, from 1
+	Type string // such as "server.created"
+	Data []byte // JSON
+}
+
+// A Hub publishes events to its subscribers. Events only reach subscribers
+// of the same hub, so of the same process.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      int64
+	recent      []Event // the most recent events, oldest first
+	subscribers map[chan Event]bool
+	closed      bool
+}
+
+// New returns a Hub without subscribers.
+func New() *Hub {
+	return &Hub{subscribers: map[chan Event]bool{}}
+}
+
+// Publish sends an event to every subscriber, dropping those that have
+// fallen too far behind.
+func (h *Hub) Publish(eventType string, data []byte) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	e := Event{ID: h.lastID, Type: eventType, Data: data}
+	h.recent = append(h.recent, e)
+	if len(h.recent) > Kept {
+		h.recent = h.recent[len(h.recent)-Kept:]
+	}
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+	return e
+}
+
+// Subscribe returns a channel of the events after lastID (0 for only new
+// events), and a function to call to unsubscribe. The channel is closed if
+// the subscriber falls behind or the hub is closed. If events after lastID
+// are no longer kept, complete is false: the subscriber has missed some.
+func (h *Hub) Subscribe(lastID int64) (events <-chan Event, unsubscribe func(), complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Event, Buffered+Kept)
+	complete = true
+	if lastID > 0 && lastID < h.lastID {
+		complete = len(h.recent) > 0 && h.recent[0].ID <= lastID+1
+		for _, e := range h.recent {
+			if e.ID > lastID {
+				ch <- e
+			}
+		}
+	}
+	if lastID > h.lastID {
+		// From before a restart: the IDs have started again
+		complete = false
+	}
+	if h.closed {
+		close(ch)
+		return ch, func() {}, complete
+	}
+	h.subscribers[ch] = true
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.subscribers[ch] {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}, complete
+}
+
+// Close ends every subscription, as when shutting down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"strconv"
+	"testing"
+)
+
+func ids(ch <-chan Event) []int64 {
+	var got []int64
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return append(got, -1)
+			}
+			got = append(got, e.ID)
+		default:
+			return got
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	h := New()
+	ch, unsubscribe, complete := h.Subscribe(0)
+	if !complete {
+		t.Error("Expected a new subscription to be complete")
+	}
+	h.Publish("server.created", []byte(`{"id":1}`))
+	h.Publish("server.deleted", []byte(`{"id":1}`))
+	if got := ids(ch); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("Expected events 1 and 2. Got %v", got)
+	}
+
+	unsubscribe()
+	h.Publish("server.created", []byte(`{"id":2}`))
+	if got := ids(ch); len(got) != 1 || got[0] != -1 {
+		t.Errorf("Expected the channel to be closed. Got %v", got)
+	}
+	unsubscribe()
+}
+
+func TestCatchUp(t *testing.T) {
+	h := New()
+	for i := 0; i < Kept+10; i++ {
+		h.Publish("server.created", []byte(`{"id":`+strconv.Itoa(i)+`}`))
+	}
+
+	ch, unsubscribe, complete := h.Subscribe(Kept + 5)
+	defer unsubscribe()
+	if got := ids(ch); !complete || len(got) != 5 || got[0] != Kept+6 {
+		t.Errorf("Expected to catch up from event %d. Got %v %v", Kept+6, complete, got)
+	}
+
+	// Some events are no longer kept
+	ch, unsubscribe, complete = h.Subscribe(5)
+	defer unsubscribe()
+	if got := ids(ch); complete || len(got) != Kept {
+		t.Errorf("Expected an incomplete catch up of %d events. Got %v %d", Kept, complete, len(got))
+	}
+
+	// From before a restart
+	if _, unsubscribe, complete := h.Subscribe(Kept * 10); complete {
+		t.Error("Expected an unknown event ID to be incomplete")
+	} else {
+		unsubscribe()
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	h := New()
+	ch, unsubscribe, _ := h.Subscribe(0)
+	defer unsubscribe()
+	for i := 0; i < Buffered+Kept+1; i++ {
+		h.Publish("server.created", nil)
+	}
+	if got := ids(ch); got[len(got)-1] != -1 {
+		t.Errorf("Expected a slow subscriber to be dropped. Got %d events", len(got))
+	}
+}
+
+func TestClose(t *testing.T) {
+	h := New()
+	ch, unsubscribe, _ := h.Subscribe(0)
+	defer unsubscribe()
+	h.Close()
+	if got := ids(ch); len(got) != 1 || got[0] != -1 {
+		t.Errorf("Expected the channel to be closed. Got %v", got)
+	}
+	late, _, _ := h.Subscribe(0)
+	if got := ids(late); len(got) != 1 || got[0] != -1 {
+		t.Errorf("Expected a subscription after closing to be closed. Got %v", got)
+	}
+}
@@ -0,0 +1,128 @@
+// Package identity verifies the end-user identity forwarded by the web client.
+//
+// The web client authenticates to the REST server as a single API user, so it
+// names the person it is acting for in a short-lived token, signed with a key
+// shared by the two (a JWT using HS256).
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Header is the HTTP header carrying the token.
+const Header = "X-Sadmin-Identity"
+
+// Audience is the intended recipient named in every token.
+const Audience = "sadmin-api"
+
+// Leeway allows for clock skew between the web client and the REST server.
+const Leeway = 30 * time.Second
+
+// Roles, in increasing order of privilege.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// ValidRole reports whether role is one of the roles.
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// Identity is the user on whose behalf a request is made.
+type Identity struct {
+	User string
+	Role string
+}
+
+// HasRole reports whether the identity has at least the specified role.
+func (id Identity) HasRole(role string) bool {
+	return roleRank[id.Role] >= roleRank[role]
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type claims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	Audience  string `json:"aud"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// ErrInvalid is returned for any token that cannot be trusted.
+var ErrInvalid = errors.New("invalid identity token")
+
+func sign(key []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// Sign returns a token for the user and role, valid for ttl.
+func Sign(key []byte, user, role string, ttl time.Duration) (string, error) {
+	h, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	c, err := json.Marshal(claims{
+		Subject:   user,
+		Role:      role,
+		Audience:  Audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(key, signed)), nil
+}
+
+// Verify checks the token's signature, audience and lifetime, and returns
+// the identity it carries.
+func Verify(key []byte, token string, now time.Time) (Identity, error) {
+	if len(key) == 0 {
+		return Identity{}, ErrInvalid
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, ErrInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, sign(key, parts[0]+"."+parts[1])) {
+		return Identity{}, ErrInvalid
+	}
+
+	var h header
+	if b, err := base64.RawURLEncoding.DecodeString(parts[0]); err != nil || json.Unmarshal(b, &h) != nil || h.Alg != "HS256" {
+		return Identity{}, ErrInvalid
+	}
+	var c claims
+	if b, err := base64.RawURLEncoding.DecodeString(parts[1]); err != nil || json.Unmarshal(b, &c) != nil {
+		return Identity{}, ErrInvalid
+	}
+
+	if c.Audience != Audience || c.Subject == "" || roleRank[c.Role] == 0 {
+		return Identity{}, ErrInvalid
+	}
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(Leeway)) || now.Before(time.Unix(c.IssuedAt, 0).Add(-Leeway)) {
+		return Identity{}, ErrInvalid
+	}
+
+	return Identity{User: c.Subject, Role: c.Role}, nil
+}
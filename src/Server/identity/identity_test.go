@@ -0,0 +1,62 @@
+package identity
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var key = []byte("0123456789abcdef0123456789abcdef")
+
+func TestRoundTrip(t *testing.T) {
+	token, err := Sign(key, "jdoe", RoleEditor, time.Minute)
+	if err != nil {
+		t.Fatalf("Error on Sign: %v", err)
+	}
+	id, err := Verify(key, token, time.Now())
+	if err != nil {
+		t.Fatalf("Error on Verify: %v", err)
+	}
+	if id.User != "jdoe" || id.Role != RoleEditor {
+		t.Errorf("Expected 'jdoe' as editor. Got '%s' as '%s'", id.User, id.Role)
+	}
+	if !id.HasRole(RoleViewer) || id.HasRole(RoleAdmin) {
+		t.Error("Editor role has the wrong privileges!")
+	}
+}
+
+func TestWrongKey(t *testing.T) {
+	token, _ := Sign(key, "jdoe", RoleAdmin, time.Minute)
+	if _, err := Verify([]byte("another key"), token, time.Now()); err == nil {
+		t.Error("Token signed with another key passed!")
+	}
+	if _, err := Verify(nil, token, time.Now()); err == nil {
+		t.Error("Token passed without a key!")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	token, _ := Sign(key, "jdoe", RoleAdmin, time.Minute)
+	if _, err := Verify(key, token, time.Now().Add(2*time.Minute)); err == nil {
+		t.Error("Expired token passed!")
+	}
+}
+
+func TestTampered(t *testing.T) {
+	token, _ := Sign(key, "jdoe", RoleViewer, time.Minute)
+	admin, _ := Sign(key, "jdoe", RoleAdmin, time.Minute)
+
+	// Splice the admin claims onto the viewer signature
+	v, a := strings.Split(token, "."), strings.Split(admin, ".")
+	if _, err := Verify(key, v[0]+"."+a[1]+"."+v[2], time.Now()); err == nil {
+		t.Error("Tampered token passed!")
+	}
+}
+
+func TestValidRole(t *testing.T) {
+	for role, want := range map[string]bool{RoleViewer: true, RoleEditor: true, RoleAdmin: true, "": false, "root": false} {
+		if ValidRole(role) != want {
+			t.Errorf("ValidRole(%q) - Expected %v", role, want)
+		}
+	}
+}
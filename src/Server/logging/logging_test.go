@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDInLogs(t *testing.T) {
+	var b bytes.Buffer
+	logger, err := New(&b, "info", "json")
+	if err != nil {
+		t.Fatalf("Error on New: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.InfoContext(ctx, "Hello", "user", "auth_user")
+	logger.DebugContext(ctx, "Not logged at info level")
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Fatalf("Expected a single JSON line. Got %s", b.String())
+	}
+	if m["request_id"] != "abc123" || m["msg"] != "Hello" || m["user"] != "auth_user" || m["level"] != "INFO" {
+		t.Errorf("Unexpected log line %s", b.String())
+	}
+}
+
+func TestTextFormat(t *testing.T) {
+	var b bytes.Buffer
+	logger, err := New(&b, "debug", "text")
+	if err != nil {
+		t.Fatalf("Error on New: %v", err)
+	}
+	logger.DebugContext(WithRequestID(context.Background(), "abc123"), "Hello")
+	if !strings.Contains(b.String(), "level=DEBUG") || !strings.Contains(b.String(), "request_id=abc123") {
+		t.Errorf("Unexpected log line %s", b.String())
+	}
+}
+
+func TestBadSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "loud", "json"); err == nil {
+		t.Error("Unknown level accepted!")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("Unknown format accepted!")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	if !ValidRequestID(NewRequestID()) {
+		t.Error("Generated request ID refused!")
+	}
+	for _, id := range []string{"", "a b", "a\nb", strings.Repeat("a", 65)} {
+		if ValidRequestID(id) {
+			t.Errorf("Invalid request ID '%q' accepted!", id)
+		}
+	}
+}
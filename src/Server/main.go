@@ -1,21 +1,81 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	// local import
 	"admin-server/application"
+	"admin-server/config"
+	"admin-server/logging"
+	"admin-server/tracing"
 )
 
 func main() {
+	args := os.Args[1:]
+
+	if len(args) > 0 && args[0] == "secrets" {
+		os.Exit(secretsCommand(args[1:]))
+	}
+
+	printConfig := len(args) > 1 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
+
+	cfg, err := config.Load("admin_server", args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		cfg.Print(os.Stdout)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		return
+	}
+
+	if err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, "sadmin-server", cfg.Tracing.Endpoint)
+	if err != nil {
+		slog.Error("Error configuring tracing", "error", err)
+		os.Exit(1)
+	}
+
 	app := application.App{}
-	app.Initialize(
-		os.Getenv("MYSQL_HOST"),
-		os.Getenv("MYSQL_PORT"),
-		os.Getenv("MYSQL_USER"),
-		os.Getenv("MYSQL_PASSWORD"),
-		os.Getenv("MYSQL_DB"),
-		os.Getenv("AUTH_USER"),
-		os.Getenv("AUTH_PASSWORD"))
-	app.Run(os.Getenv("PORT"))
+	app.Initialize(cfg)
+
+	if err := app.Connect(ctx); err != nil {
+		app.Close()
+		slog.Error("Error on connecting to database", "error", err)
+		os.Exit(1)
+	}
+
+	err = app.Run(ctx)
+	if cerr := app.Close(); cerr != nil {
+		slog.Error("Error on closing database", "error", cerr)
+	}
+	if terr := shutdownTracing(context.Background()); terr != nil {
+		slog.Error("Error on flushing traces", "error", terr)
+	}
+	if err != nil {
+		slog.Error("Error on serve", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Shut down cleanly")
 }
@@ -0,0 +1,92 @@
+// Package middleware wraps the request handlers of the REST server and the web
+// client alike, so that every request is logged, traced and measured the same way.
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	// local packages
+	"admin-server/logging"
+	"admin-server/tracing"
+
+	// GitHub packages
+	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// An Observer records the metrics of a request once it has been handled.
+type Observer func(method, route string, status int, elapsed time.Duration)
+
+// Track gives each request to the route a request ID (the caller's, if it sent
+// one), returns it in the response, traces the request, logs it and has it
+// observed.
+func Track(method, route string, observe Observer, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		start := time.Now()
+
+		id := req.Header.Get(logging.Header)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(logging.Header, id)
+		ctx, span := tracing.StartServer(req, route)
+		span.SetAttributes(attribute.String("request.id", id))
+		req = req.WithContext(logging.WithRequestID(ctx, id))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		h(rec, req, ps)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		tracing.EndServer(span, rec.status)
+		elapsed := time.Since(start)
+		observe(method, route, rec.status, elapsed)
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case rec.status >= 400:
+			level = slog.LevelWarn
+		}
+		slog.Log(req.Context(), level, "Request", "method", method, "path", req.URL.Path, "route", route,
+			"status", rec.status, "duration_ms", elapsed.Milliseconds(), "ip", ClientIP(req))
+	}
+}
+
+// ClientIP is the address a request came from, for throttling and logging.
+func ClientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	// local packages
+	"admin-server/logging"
+
+	// GitHub packages
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestTrack(t *testing.T) {
+	var observed []int
+	observe := func(method, route string, status int, elapsed time.Duration) {
+		if method != "GET" || route != "/servers/:id" {
+			t.Errorf("Unexpected method '%s' or route '%s'", method, route)
+		}
+		observed = append(observed, status)
+	}
+	var seen string
+	h := Track("GET", "/servers/:id", observe, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		seen = logging.RequestID(req.Context())
+		if ps.ByName("id") == "0" {
+			http.NotFound(w, req)
+		}
+	})
+
+	req := httptest.NewRequest("GET", "/servers/1", nil)
+	req.Header.Set(logging.Header, "from-caller")
+	rr := httptest.NewRecorder()
+	h(rr, req, httprouter.Params{{Key: "id", Value: "1"}})
+	if id := rr.Header().Get(logging.Header); id != "from-caller" || seen != "from-caller" {
+		t.Errorf("Expected the caller's request ID to be used and returned. Got '%s' and '%s'", seen, id)
+	}
+
+	req = httptest.NewRequest("GET", "/servers/0", nil)
+	req.Header.Set(logging.Header, "not\tvalid")
+	rr = httptest.NewRecorder()
+	h(rr, req, httprouter.Params{{Key: "id", Value: "0"}})
+	if id := rr.Header().Get(logging.Header); !logging.ValidRequestID(id) || seen != id {
+		t.Errorf("Expected a new request ID. Got '%s' and '%s'", seen, id)
+	}
+
+	if len(observed) != 2 || observed[0] != http.StatusOK || observed[1] != http.StatusNotFound {
+		t.Errorf("Expected statuses 200 and 404 to be observed. Got %v", observed)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if ip := ClientIP(req); ip != "192.0.2.1" {
+		t.Errorf("Expected '192.0.2.1'. Got '%s'", ip)
+	}
+	req.RemoteAddr = "pipe"
+	if ip := ClientIP(req); ip != "pipe" {
+		t.Errorf("Expected 'pipe'. Got '%s'", ip)
+	}
+}
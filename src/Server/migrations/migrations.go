@@ -0,0 +1,142 @@
+// Package migrations keeps the database schema up to date.
+//
+// Each migration is applied once, in order of version, and recorded in the
+// schema_migrations table. Migrations must never be changed once released;
+// add a new one instead.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+var migrations = []migration{
+	{1, "create servers table", []string{
+		// IF NOT EXISTS, as the table predates migrations
+		`CREATE TABLE IF NOT EXISTS servers
+(
+	id BIGINT(20) AUTO_INCREMENT,
+	name VARCHAR(50) NOT NULL UNIQUE,
+	PRIMARY KEY (id)
+)`,
+	}},
+	{2, "allow server names as long as DNS host names", []string{
+		"ALTER TABLE servers MODIFY name VARCHAR(253) NOT NULL",
+	}},
+	{3, "record when servers are created and updated", []string{
+		`ALTER TABLE servers
+	ADD created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	ADD updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP`,
+	}},
+	{4, "add server statuses and tags", []string{
+		"ALTER TABLE servers ADD status VARCHAR(20) NOT NULL DEFAULT 'active'",
+		`CREATE TABLE server_tags
+(
+	server_id BIGINT(20) NOT NULL,
+	tag VARCHAR(50) NOT NULL,
+	PRIMARY KEY (server_id, tag),
+	INDEX (tag),
+	FOREIGN KEY (server_id) REFERENCES servers (id) ON DELETE CASCADE
+)`,
+	}},
+}
+
+const migrationsTableCreationQuery = `CREATE TABLE IF NOT EXISTS schema_migrations
+(
+	version INT NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (version)
+)`
+
+// Only one server at a time may apply migrations
+const lockName = "sadmin_migrations"
+const lockTimeout = 60 // seconds
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func applied(ctx context.Context, db queryer) (map[int]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions[v] = true
+	}
+	return versions, rows.Err()
+}
+
+// Apply applies any migrations which have not yet been applied.
+func Apply(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("timed out waiting for another server to apply migrations")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+
+	if _, err := conn.ExecContext(ctx, migrationsTableCreationQuery); err != nil {
+		return err
+	}
+	done, err := applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if done[m.version] {
+			continue
+		}
+		slog.Info("Applying migration", "version", m.version, "description", m.description)
+		for _, stmt := range m.statements {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("migration %d: %v", m.version, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, description) VALUES (?, ?)",
+			m.version, m.description); err != nil {
+			return fmt.Errorf("migration %d: %v", m.version, err)
+		}
+	}
+	return nil
+}
+
+// Pending returns the number of migrations which have not yet been applied.
+func Pending(ctx context.Context, db *sql.DB) (int, error) {
+	done, err := applied(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, m := range migrations {
+		if !done[m.version] {
+			n++
+		}
+	}
+	return n, nil
+}
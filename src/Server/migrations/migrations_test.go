@@ -0,0 +1,14 @@
+package migrations
+
+import "testing"
+
+func TestMigrationOrder(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("Expected migration %d to be version %d. Got %d", i, i+1, m.version)
+		}
+		if m.description == "" || len(m.statements) == 0 {
+			t.Errorf("Migration %d is incomplete", m.version)
+		}
+	}
+}
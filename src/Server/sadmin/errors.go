@@ -0,0 +1,91 @@
+package sadmin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Errors which an *Error may be compared to with errors.Is.
+var (
+	ErrUnauthorized = errors.New("sadmin: unauthorized")
+	ErrForbidden    = errors.New("sadmin: forbidden")
+	ErrNotFound     = errors.New("sadmin: not found")
+	ErrConflict     = errors.New("sadmin: conflict")
+)
+
+// Error is an error response from the REST server, which describes it as
+// RFC 7807 problem details.
+type Error struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id"`
+	Errors    []FieldError `json:"errors"`
+
+	// RetryAfter is how long a throttled client was asked to wait.
+	RetryAfter time.Duration `json:"-"`
+}
+
+// FieldError describes what is wrong with one field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("sadmin: %d %s", e.Status, e.Title)
+	if e.Code != "" {
+		msg += " (" + e.Code + ")"
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Is reports whether the error is one of ErrUnauthorized, ErrForbidden,
+// ErrNotFound or ErrConflict.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.Status == http.StatusForbidden
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrConflict:
+		return e.Status == http.StatusConflict
+	}
+	return false
+}
+
+// readError returns the error described by an unsuccessful response, or one
+// made up from its status if the response is not problem+json.
+func readError(resp *http.Response, body []byte) *Error {
+	e := &Error{}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" || json.Unmarshal(body, e) != nil {
+		e = &Error{}
+	}
+	if e.Status == 0 {
+		e.Status = resp.StatusCode
+	}
+	if e.Title == "" {
+		e.Title = http.StatusText(resp.StatusCode)
+	}
+	if e.RequestID == "" {
+		e.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return e
+}
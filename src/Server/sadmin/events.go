@@ -0,0 +1,102 @@
+package sadmin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Types of Event
+const (
+	EventCreated  = "server.created"
+	EventModified = "server.modified"
+	EventDeleted  = "server.deleted"
+
+	// EventReset means that some changes were missed, so any servers already
+	// read should be read again.
+	EventReset = "reset"
+)
+
+// Event is a change to the inventory, reported by Watch.
+type Event struct {
+	// ID is where to resume watching after this event.
+	ID   string
+	Type string
+	// Data is the server for EventCreated and EventModified, and only its ID
+	// for EventDeleted.
+	Data json.RawMessage
+}
+
+// Server decodes the server changed by the event.
+func (e Event) Server() (Server, error) {
+	var s Server
+	err := json.Unmarshal(e.Data, &s)
+	return s, err
+}
+
+// Most an event may hold
+const maxEventSize = 1 << 20
+
+// Watch calls fn with each change to the inventory after the event with
+// lastID (blank for only changes from now on), until ctx is done, fn returns
+// an error or the REST server ends the stream, as when it shuts down. It is
+// not retried: call it again, with the ID of the last event, to carry on.
+// The HTTP client must not have a timeout, which would cut the stream short.
+func (c *Client) Watch(ctx context.Context, lastID string, fn func(Event) error) error {
+	req, err := c.newRequest(ctx, "GET", "/v1/events", nil, "", "text/event-stream, application/problem+json")
+	if err != nil {
+		return err
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return readError(resp, b)
+	}
+
+	// Events are fields, one to a line, up to a blank line (Server-Sent Events)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 4096), maxEventSize)
+	var e Event
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if e.Type != "" || len(data) > 0 {
+				e.Data = json.RawMessage(strings.Join(data, "\n"))
+				if err := fn(e); err != nil {
+					return err
+				}
+			}
+			e, data = Event{ID: e.ID}, nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			e.ID = value
+		case "event":
+			e.Type = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("sadmin: reading events: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+// Package sadmin is a client for the Sadmin REST API.
+//
+//	c := sadmin.New("https://localhost:8100", nil)
+//	c.User, c.Password = "api_user", "api_password"
+//	s, err := c.Create(ctx, "web-01.example.com")
+//	if errors.Is(err, sadmin.ErrConflict) {
+//		...
+//	}
+//
+// Failed calls return an *Error describing the problem. Calls which are safe
+// to repeat are retried, with backoff, if the REST server cannot be reached
+// or is temporarily unavailable. Throttled calls are retried after the wait
+// the REST server asks for.
+package sadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Server is an entry in the inventory.
+type Server struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Client calls the REST server. Its fields must not be changed while it is
+// in use.
+type Client struct {
+	// BaseURL is the REST server, such as https://localhost:8100
+	BaseURL string
+
+	// HTTPClient makes the requests.
+	HTTPClient *http.Client
+
+	// User and Password are the API user's credentials, needed for changes.
+	User     string
+	Password string
+
+	// Prepare, if set, is called with each request before it is sent, for
+	// example to add headers.
+	Prepare func(req *http.Request)
+
+	// MaxRetries is how many more times a call which is safe to repeat is
+	// made, first after RetryDelay, then doubling the delay each time.
+	MaxRetries int
+	RetryDelay time.Duration
+}
+
+// Defaults for retrying calls
+const defaultMaxRetries = 2
+const defaultRetryDelay = 200 * time.Millisecond
+
+// Longest a call waits to be retried when throttled
+const maxRetryAfter = 10 * time.Second
+
+// New returns a client for the REST server at baseURL, making requests with
+// httpClient, or http.DefaultClient if nil.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: httpClient,
+		MaxRetries: defaultMaxRetries,
+		RetryDelay: defaultRetryDelay,
+	}
+}
+
+// List returns up to count servers, in order of name, skipping the first start.
+func (c *Client) List(ctx context.Context, start, count int) ([]Server, error) {
+	var servers []Server
+	err := c.call(ctx, "GET", "/v1/servers?"+page(start, count).Encode(), nil, "", true, http.StatusOK, &servers)
+	return servers, err
+}
+
+// Search returns up to count servers whose names match the SQL LIKE pattern,
+// in order of name, skipping the first start.
+func (c *Client) Search(ctx context.Context, pattern string, start, count int) ([]Server, error) {
+	form := page(start, count)
+	form.Set("name", pattern)
+	var servers []Server
+	// Searching changes nothing, so is safe to repeat
+	err := c.call(ctx, "POST", "/v1/search/servers", []byte(form.Encode()), "application/x-www-form-urlencoded",
+		true, http.StatusOK, &servers)
+	return servers, err
+}
+
+// Query selects a page of servers.
+type Query struct {
+	// Name is a SQL LIKE pattern servers must match, such as 'web-%', or
+	// empty for every server.
+	Name string
+	// Sort is the order of the servers, such as "name" or "-updated_at";
+	// by name if empty.
+	Sort string
+	// Start is how many servers to skip, and Count the most to return.
+	Start int
+	Count int
+}
+
+// Page is a page of servers.
+type Page struct {
+	Servers []Server
+	// Total is how many servers the query selects in all.
+	Total int64
+}
+
+// UnmarshalJSON decodes the servers on the page.
+func (p *Page) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &p.Servers)
+}
+
+func (p *Page) readHeader(h http.Header) error {
+	total, err := strconv.ParseInt(h.Get("X-Total-Count"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid X-Total-Count: %v", err)
+	}
+	p.Total = total
+	return nil
+}
+
+// Find returns the page of servers selected by the query.
+func (c *Client) Find(ctx context.Context, q Query) (Page, error) {
+	form := page(q.Start, q.Count)
+	if q.Sort != "" {
+		form.Set("sort", q.Sort)
+	}
+	var p Page
+	if q.Name == "" {
+		err := c.call(ctx, "GET", "/v1/servers?"+form.Encode(), nil, "", true, http.StatusOK, &p)
+		return p, err
+	}
+	form.Set("name", q.Name)
+	err := c.call(ctx, "POST", "/v1/search/servers", []byte(form.Encode()), "application/x-www-form-urlencoded",
+		true, http.StatusOK, &p)
+	return p, err
+}
+
+// Get returns the server with the ID.
+func (c *Client) Get(ctx context.Context, id int64) (Server, error) {
+	var s Server
+	err := c.call(ctx, "GET", serverPath(id), nil, "", true, http.StatusOK, &s)
+	return s, err
+}
+
+// Create adds a server with the name, returning it as stored.
+func (c *Client) Create(ctx context.Context, name string) (Server, error) {
+	return c.write(ctx, "POST", "/v1/servers", nameField{name}, false, http.StatusCreated)
+}
+
+// Update replaces the server with the ID, returning it as stored.
+func (c *Client) Update(ctx context.Context, id int64, name string) (Server, error) {
+	return c.write(ctx, "PUT", serverPath(id), nameField{name}, true, http.StatusOK)
+}
+
+// Patch modifies the server with the ID, returning it as stored.
+func (c *Client) Patch(ctx context.Context, id int64, name string) (Server, error) {
+	return c.write(ctx, "PATCH", serverPath(id), nameField{name}, false, http.StatusOK)
+}
+
+// SetStatus changes the status of the server with the ID, such as to
+// "maintenance", returning it as stored.
+func (c *Client) SetStatus(ctx context.Context, id int64, status string) (Server, error) {
+	body := struct {
+		Status string `json:"status"`
+	}{status}
+	// Setting the status again changes nothing, so is safe to repeat
+	return c.write(ctx, "PATCH", serverPath(id), body, true, http.StatusOK)
+}
+
+// SetTags replaces the tags of the server with the ID, returning it as stored.
+func (c *Client) SetTags(ctx context.Context, id int64, tags []string) (Server, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	body := struct {
+		Tags []string `json:"tags"`
+	}{tags}
+	return c.write(ctx, "PATCH", serverPath(id), body, true, http.StatusOK)
+}
+
+// AddTags adds tags to the server with the ID, keeping those it has, and
+// returns it as stored. Unlike SetTags, tags added by others at the same time
+// are not lost.
+func (c *Client) AddTags(ctx context.Context, id int64, tags []string) (Server, error) {
+	body := struct {
+		Tags []string `json:"tags"`
+	}{tags}
+	// Adding the tags again changes nothing, so is safe to repeat
+	return c.write(ctx, "POST", serverPath(id)+"/tags", body, true, http.StatusOK)
+}
+
+// Delete removes the server with the ID. If a retry finds the server already
+// gone, an earlier attempt deleted it but its response was lost, so that is
+// success too.
+func (c *Client) Delete(ctx context.Context, id int64) error {
+	return c.call(ctx, "DELETE", serverPath(id), nil, "", true, http.StatusOK, nil)
+}
+
+// Health checks that the REST server is alive.
+func (c *Client) Health(ctx context.Context) error {
+	return c.call(ctx, "GET", "/healthz", nil, "", false, http.StatusOK, nil)
+}
+
+// nameField is a change to the name alone.
+type nameField struct {
+	Name string `json:"name"`
+}
+
+func (c *Client) write(ctx context.Context, method, path string, fields interface{}, idempotent bool, want int) (Server, error) {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return Server{}, err
+	}
+	var s Server
+	err = c.call(ctx, method, path, body, "application/json", idempotent, want, &s)
+	return s, err
+}
+
+func serverPath(id int64) string {
+	return "/v1/servers/" + strconv.FormatInt(id, 10)
+}
+
+func page(start, count int) url.Values {
+	return url.Values{"start": {strconv.Itoa(start)}, "count": {strconv.Itoa(count)}}
+}
+
+// headerReader is a result which also reads the response headers.
+type headerReader interface {
+	readHeader(h http.Header) error
+}
+
+// call makes the request, retrying it if idempotent, and decodes a response
+// with the wanted status into result, if not nil. A throttled request was not
+// acted on, so is retried whether idempotent or not, after waiting as long as
+// the REST server asks, unless that is longer than maxRetryAfter.
+func (c *Client) call(ctx context.Context, method, path string, body []byte, contentType string,
+	idempotent bool, want int, result interface{}) error {
+
+	delay := c.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := c.do(ctx, method, path, body, contentType, want, result)
+		if attempt > 0 && method == "DELETE" && errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		wait := delay
+		var e *Error
+		throttled := errors.As(err, &e) && e.Status == http.StatusTooManyRequests
+		if throttled && e.RetryAfter > wait {
+			wait = e.RetryAfter
+		}
+		if err == nil || !retry || !idempotent && !throttled || attempt >= c.MaxRetries || wait > maxRetryAfter {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// newRequest returns a request to the REST server, with the credentials and
+// anything added by Prepare.
+func (c *Client) newRequest(ctx context.Context, method, path string, body []byte, contentType, accept string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Password)
+	}
+	if c.Prepare != nil {
+		c.Prepare(req)
+	}
+	return req, nil
+}
+
+// do makes the request once, reporting whether an error may be temporary.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, contentType string,
+	want int, result interface{}) (temporary bool, err error) {
+
+	req, err := c.newRequest(ctx, method, path, body, contentType, "application/json, application/problem+json")
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	if resp.StatusCode != want {
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true, readError(resp, b)
+		}
+		return false, readError(resp, b)
+	}
+	if result != nil {
+		if err := json.Unmarshal(b, result); err != nil {
+			return false, fmt.Errorf("sadmin: invalid response to %s %s: %v", method, path, err)
+		}
+	}
+	if hr, ok := result.(headerReader); ok {
+		if err := hr.readHeader(resp.Header); err != nil {
+			return false, fmt.Errorf("sadmin: invalid response to %s %s: %v", method, path, err)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,230 @@
+package sadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestClient(h http.HandlerFunc) (*Client, func()) {
+	ts := httptest.NewServer(h)
+	c := New(ts.URL, nil)
+	c.User, c.Password = "api", "secret"
+	c.RetryDelay = time.Millisecond
+	return c, ts.Close
+}
+
+func problem(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"title":"%s","status":%d,"code":"%s","detail":"Oops","request_id":"req-1",`+
+		`"errors":[{"field":"name","code":"duplicate","message":"in use"}]}`, http.StatusText(status), status, code)
+}
+
+func TestCreate(t *testing.T) {
+	var body string
+	c, done := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		if u, p, _ := r.BasicAuth(); r.Method != "POST" || r.URL.Path != "/v1/servers" || u != "api" || p != "secret" {
+			t.Errorf("Unexpected request %s %s as %s", r.Method, r.URL.Path, u)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7,"name":"web-01.example.com"}`))
+	})
+	defer done()
+
+	// Names are encoded, not pasted into the JSON
+	s, err := c.Create(context.Background(), `Web-01"}.example.com`)
+	if err != nil || s.ID != 7 || s.Name != "web-01.example.com" {
+		t.Errorf("Expected the created server. Got %+v, %v", s, err)
+	}
+	if body != `{"name":"Web-01\"}.example.com"}` {
+		t.Errorf("Unexpected payload %s", body)
+	}
+}
+
+func TestFind(t *testing.T) {
+	c, done := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		want := map[bool]string{false: "GET /v1/servers", true: "POST /v1/search/servers"}[r.FormValue("name") != ""]
+		if r.Method+" "+r.URL.Path != want || r.FormValue("sort") != "-name" || r.FormValue("start") != "10" {
+			t.Errorf("Unexpected request %s %s %v", r.Method, r.URL.Path, r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Count", "12")
+		w.Write([]byte(`[{"id":7,"name":"web-01.example.com"},{"id":8,"name":"web-02.example.com"}]`))
+	})
+	defer done()
+
+	for _, name := range []string{"", "web-%"} {
+		p, err := c.Find(context.Background(), Query{Name: name, Sort: "-name", Start: 10, Count: 5})
+		if err != nil || len(p.Servers) != 2 || p.Servers[1].ID != 8 || p.Total != 12 {
+			t.Errorf("Expected a page of 2 of 12 servers. Got %+v, %v", p, err)
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	for status, want := range map[int]error{
+		http.StatusUnauthorized: ErrUnauthorized,
+		http.StatusForbidden:    ErrForbidden,
+		http.StatusNotFound:     ErrNotFound,
+		http.StatusConflict:     ErrConflict,
+	} {
+		c, done := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			problem(w, status, "some_code")
+		})
+		_, err := c.Get(context.Background(), 1)
+		done()
+
+		if !errors.Is(err, want) {
+			t.Errorf("%d - Expected %v. Got %v", status, want, err)
+		}
+		var e *Error
+		if !errors.As(err, &e) || e.Code != "some_code" || e.RequestID != "req-1" || len(e.Errors) != 1 {
+			t.Errorf("%d - Expected the problem details. Got %+v", status, e)
+		}
+	}
+}
+
+func TestNotProblem(t *testing.T) {
+	c, done := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "req-2")
+		http.Error(w, "upstream error", http.StatusInternalServerError)
+	})
+	defer done()
+
+	err := c.Delete(context.Background(), 1)
+	var e *Error
+	if !errors.As(err, &e) || e.Status != http.StatusInternalServerError || e.Title != "Internal Server Error" || e.RequestID != "req-2" {
+		t.Errorf("Expected an error made up from the status. Got %+v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	calls := 0
+	c, done := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			problem(w, http.StatusServiceUnavailable, "unavailable")
+			return
+		}
+		if r.Method == "DELETE" {
+			problem(w, http.StatusNotFound, "not_found")
+			return
+		}
+		w.Write([]byte(`[{"id":1,"name":"a.example.com"}]`))
+	})
+	defer done()
+
+	servers, err := c.List(context.Background(), 0, 25)
+	if err != nil || len(servers) != 1 || calls != 3 {
+		t.Errorf("Expected success on the 3rd call. Got %v after %d calls", err, calls)
+	}
+
+	// Creating is not safe to repeat
+	calls = 0
+	if _, err := c.Create(context.Background(), "a.example.com"); err == nil || calls != 1 {
+		t.Errorf("Expected a single failed call. Got %v after %d calls", err, calls)
+	}
+
+	// A retried delete which finds the server gone has succeeded, but not a
+	// first attempt
+	calls = 1
+	if err := c.Delete(context.Background(), 1); err != nil || calls != 3 {
+		t.Errorf("Expected success on the 2nd call. Got %v after %d calls", err, calls-1)
+	}
+	if err := c.Delete(context.Background(), 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found. Got %v", err)
+	}
+
+	// Nor are calls retried forever
+	calls = -10
+	if _, err := c.Get(context.Background(), 1); err == nil || calls != -10+c.MaxRetries+1 {
+		t.Errorf("Expected to give up after %d calls. Got %d", c.MaxRetries+1, calls+10)
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	calls := 0
+	c, done := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 || r.Method == "GET" {
+			w.Header().Set("Retry-After", map[string]string{"POST": "1", "GET": "60"}[r.Method])
+			problem(w, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7,"name":"a.example.com"}`))
+	})
+	defer done()
+
+	// Even creating is retried, as a throttled request was not acted on
+	start := time.Now()
+	if _, err := c.Create(context.Background(), "a.example.com"); err != nil || calls != 2 || time.Since(start) < time.Second {
+		t.Errorf("Expected success on the 2nd call after a second. Got %v after %d calls", err, calls)
+	}
+
+	// But not if the wait is too long
+	calls = 0
+	_, err := c.Get(context.Background(), 1)
+	var e *Error
+	if !errors.As(err, &e) || e.RetryAfter != time.Minute || calls != 1 {
+		t.Errorf("Expected a single throttled call. Got %+v after %d calls", err, calls)
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	c, done := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Request-ID") != "from-prepare" {
+			t.Errorf("Expected the header added by Prepare")
+		}
+		if r.FormValue("name") != "web-%" || r.FormValue("count") != "10" {
+			t.Errorf("Unexpected search form %v", r.Form)
+		}
+		w.Write([]byte(`[]`))
+	})
+	defer done()
+	c.Prepare = func(req *http.Request) { req.Header.Set("X-Request-ID", "from-prepare") }
+
+	if servers, err := c.Search(context.Background(), "web-%", 0, 10); err != nil || len(servers) != 0 {
+		t.Errorf("Expected no servers. Got %v, %v", servers, err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c, done := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/events" || r.Header.Get("Last-Event-ID") != "4" {
+			t.Errorf("Unexpected request %s %s", r.URL.Path, r.Header.Get("Last-Event-ID"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "retry: 3000\n\n: keep-alive\n\n")
+		fmt.Fprint(w, "id: 5\nevent: server.created\ndata: {\"id\":7,\"name\":\"web-01.example.com\"}\n\n")
+		fmt.Fprint(w, "id: 6\nevent: server.deleted\ndata: {\"id\":7}\n\n")
+	})
+	defer done()
+
+	var got []Event
+	err := c.Watch(context.Background(), "4", func(e Event) error {
+		got = append(got, e)
+		return nil
+	})
+	if err != nil || len(got) != 2 || got[0].ID != "5" || got[0].Type != EventCreated || got[1].Type != EventDeleted {
+		t.Fatalf("Expected two events. Got %+v, %v", got, err)
+	}
+	if s, err := got[0].Server(); err != nil || s.ID != 7 || s.Name != "web-01.example.com" {
+		t.Errorf("Expected the created server. Got %+v, %v", s, err)
+	}
+
+	stop := errors.New("stop")
+	if err := c.Watch(context.Background(), "4", func(Event) error { return stop }); err != stop {
+		t.Errorf("Expected watching to stop with the callback's error. Got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+// Package secrets looks up credentials such as passwords and keys, so that they
+// need not be passed in plain environment variables.
+//
+// A secret NAME may be found in any of the following, in order of precedence:
+//
+//	the file named by the environment variable NAME_FILE
+//	a KEY=VALUE config file, named by SECRETS_CONFIG_FILE
+//	an encrypted secrets file, named by SECRETS_ENCRYPTED_FILE,
+//	    which is decrypted with the key in the file named by SECRETS_KEY_FILE
+//	the environment variable NAME
+//
+// Config and key files must not be accessible by group or others.
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Provider is a source of secrets.
+type Provider interface {
+	// Lookup returns the named secret, and whether it was found.
+	Lookup(name string) (string, bool, error)
+}
+
+// Chain looks up secrets in each of its providers in turn.
+type Chain []Provider
+
+// Lookup returns the secret from the first provider that has it.
+func (c Chain) Lookup(name string) (string, bool, error) {
+	for _, p := range c {
+		v, ok, err := p.Lookup(name)
+		if err != nil || ok {
+			return v, ok, err
+		}
+	}
+	return "", false, nil
+}
+
+// Env provides secrets from plain environment variables.
+type Env struct{}
+
+// Lookup returns the environment variable of the same name.
+func (Env) Lookup(name string) (string, bool, error) {
+	v, ok := os.LookupEnv(name)
+	return v, ok, nil
+}
+
+// EnvFile provides secrets from the files named by NAME_FILE environment
+// variables, as used for Docker secrets.
+type EnvFile struct{}
+
+// Lookup returns the contents of the file named by NAME_FILE, less any trailing newline.
+func (EnvFile) Lookup(name string) (string, bool, error) {
+	path, ok := os.LookupEnv(name + "_FILE")
+	if !ok {
+		return "", false, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("reading %s_FILE: %v", name, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), true, nil
+}
+
+// Map provides secrets from a map.
+type Map map[string]string
+
+// Lookup returns the map entry of the same name.
+func (m Map) Lookup(name string) (string, bool, error) {
+	v, ok := m[name]
+	return v, ok, nil
+}
+
+// readPrivateFile reads a file, refusing if it is accessible by group or others.
+func readPrivateFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("%s must not be accessible by group or others (mode %v)", path, info.Mode().Perm())
+	}
+	return ioutil.ReadFile(path)
+}
+
+// ParseConfig parses KEY=VALUE lines, ignoring blank lines and # comments.
+func ParseConfig(b []byte) (Map, error) {
+	m := Map{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return m, scanner.Err()
+}
+
+// LoadConfigFile returns the secrets in a KEY=VALUE config file.
+func LoadConfigFile(path string) (Map, error) {
+	b, err := readPrivateFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+const encryptedMagic = "sadmin-secrets-v1\n"
+
+// ErrDecrypt is returned when an encrypted secrets file cannot be decrypted.
+var ErrDecrypt = errors.New("cannot decrypt secrets (wrong key or corrupt file)")
+
+// GenerateKey returns a new random key for an encrypted secrets file, base64 encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// LoadKeyFile reads a base64 encoded key, as written by GenerateKey.
+func LoadKeyFile(path string) ([]byte, error) {
+	b, err := readPrivateFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s: expected a base64 encoded 32 byte key", path)
+	}
+	return key, nil
+}
+
+// Seal encrypts secrets with AES-256-GCM, for reading by OpenEncrypted.
+func Seal(key []byte, secrets Map) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(encryptedMagic))
+	return []byte(encryptedMagic + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// OpenEncrypted decrypts secrets encrypted by Seal.
+func OpenEncrypted(key, b []byte) (Map, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, []byte(encryptedMagic)) {
+		return nil, errors.New("not an encrypted secrets file")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b[len(encryptedMagic):])))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(encryptedMagic))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	m := Map{}
+	if err := json.Unmarshal(plaintext, &m); err != nil {
+		return nil, ErrDecrypt
+	}
+	return m, nil
+}
+
+// LoadEncryptedFile decrypts an encrypted secrets file with the key in keyPath.
+func LoadEncryptedFile(path, keyPath string) (Map, error) {
+	key, err := LoadKeyFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := OpenEncrypted(key, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// FromEnvironment returns the standard chain of providers, as described above.
+func FromEnvironment() (Provider, error) {
+	chain := Chain{EnvFile{}}
+
+	if path := os.Getenv("SECRETS_CONFIG_FILE"); path != "" {
+		m, err := LoadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, m)
+	}
+
+	if path := os.Getenv("SECRETS_ENCRYPTED_FILE"); path != "" {
+		keyPath := os.Getenv("SECRETS_KEY_FILE")
+		if keyPath == "" {
+			return nil, errors.New("SECRETS_ENCRYPTED_FILE requires SECRETS_KEY_FILE")
+		}
+		m, err := LoadEncryptedFile(path, keyPath)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, m)
+	}
+
+	return append(chain, Env{}), nil
+}
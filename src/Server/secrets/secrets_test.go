@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, contents string, mode os.FileMode) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), mode); err != nil {
+		t.Fatal(err)
+	}
+	// Not subject to the umask
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "password", "sadminpass\n", 0644)
+	t.Setenv("TEST_PASSWORD_FILE", path)
+
+	v, ok, err := EnvFile{}.Lookup("TEST_PASSWORD")
+	if err != nil || !ok || v != "sadminpass" {
+		t.Errorf("Expected 'sadminpass'. Got '%s' %v %v", v, ok, err)
+	}
+	if _, ok, _ := (EnvFile{}).Lookup("TEST_MISSING"); ok {
+		t.Error("Found a secret with no _FILE variable!")
+	}
+}
+
+func TestConfigFilePermissions(t *testing.T) {
+	dir := t.TempDir()
+	contents := "# Database\nMYSQL_PASSWORD = sadminpass\n\nAUTH_PASSWORD=a=b\n"
+
+	if _, err := LoadConfigFile(writeFile(t, dir, "open.conf", contents, 0644)); err == nil {
+		t.Error("World-readable config file accepted!")
+	}
+
+	m, err := LoadConfigFile(writeFile(t, dir, "private.conf", contents, 0600))
+	if err != nil {
+		t.Fatalf("Error on LoadConfigFile: %v", err)
+	}
+	if m["MYSQL_PASSWORD"] != "sadminpass" || m["AUTH_PASSWORD"] != "a=b" || len(m) != 2 {
+		t.Errorf("Unexpected secrets: %v", m)
+	}
+
+	if _, err := ParseConfig([]byte("MYSQL_PASSWORD\n")); err == nil {
+		t.Error("Line without '=' accepted!")
+	}
+}
+
+func TestEncryptedFile(t *testing.T) {
+	dir := t.TempDir()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := writeFile(t, dir, "key", key+"\n", 0600)
+
+	k, err := LoadKeyFile(keyPath)
+	if err != nil {
+		t.Fatalf("Error on LoadKeyFile: %v", err)
+	}
+	sealed, err := Seal(k, Map{"AUTH_PASSWORD": "remotepass"})
+	if err != nil {
+		t.Fatalf("Error on Seal: %v", err)
+	}
+	path := writeFile(t, dir, "secrets.enc", string(sealed), 0644)
+
+	m, err := LoadEncryptedFile(path, keyPath)
+	if err != nil {
+		t.Fatalf("Error on LoadEncryptedFile: %v", err)
+	}
+	if m["AUTH_PASSWORD"] != "remotepass" {
+		t.Errorf("Expected 'remotepass'. Got '%s'", m["AUTH_PASSWORD"])
+	}
+
+	other, _ := GenerateKey()
+	otherPath := writeFile(t, dir, "other", other, 0600)
+	if _, err := LoadEncryptedFile(path, otherPath); err == nil {
+		t.Error("Decrypted with the wrong key!")
+	}
+
+	if _, err := LoadKeyFile(writeFile(t, dir, "open", key, 0640)); err == nil {
+		t.Error("Group-readable key file accepted!")
+	}
+}
+
+func TestChain(t *testing.T) {
+	t.Setenv("TEST_SECRET", "from env")
+	chain := Chain{Map{"OTHER": "x"}, Map{"TEST_SECRET": "from map"}, Env{}}
+
+	if v, _, _ := chain.Lookup("TEST_SECRET"); v != "from map" {
+		t.Errorf("Expected 'from map'. Got '%s'", v)
+	}
+	if _, ok, _ := chain.Lookup("TEST_MISSING"); ok {
+		t.Error("Found a missing secret!")
+	}
+}
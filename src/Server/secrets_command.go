@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	// local import
+	"admin-server/secrets"
+)
+
+const secretsUsage = `usage:
+    admin_server secrets keygen > KEYFILE
+    admin_server secrets seal KEYFILE < PLAINTEXT > ENCRYPTED
+
+PLAINTEXT is KEY=VALUE lines, for example MYSQL_PASSWORD=sadminpass
+`
+
+// secretsCommand manages encrypted secrets files, returning the exit code.
+func secretsCommand(args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "keygen":
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(key)
+		return 0
+
+	case len(args) == 2 && args[0] == "seal":
+		key, err := secrets.LoadKeyFile(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		plaintext, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		m, err := secrets.ParseConfig(plaintext)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		sealed, err := secrets.Seal(key, m)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		os.Stdout.Write(sealed)
+		return 0
+	}
+
+	fmt.Fprint(os.Stderr, secretsUsage)
+	return 2
+}
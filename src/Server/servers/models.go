@@ -1,87 +1,263 @@
 package servers
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	// local import
+	"admin-server/tracing"
+)
 
 // The Server entity is used to marshall/unmarshall JSON.
 type Server struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// GetServer returns a single specified server.
-func (s *Server) GetServer(db *sql.DB) error {
+// selectServers selects servers with their tags, to be followed by any WHERE
+// clause, then groupServers.
+const selectServers = `SELECT s.id, s.name, s.status, COALESCE(GROUP_CONCAT(t.tag ORDER BY t.tag), ''), s.created_at, s.updated_at
+FROM servers s LEFT JOIN server_tags t ON t.server_id = s.id`
 
-	stmt, err := db.Prepare("SELECT name FROM servers WHERE id = ?")
-	if err != nil {
+const groupServers = " GROUP BY s.id"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scan reads a server selected with selectServers.
+func (s *Server) scan(row scanner) error {
+	var tags string
+	if err := row.Scan(&s.ID, &s.Name, &s.Status, &tags, &s.CreatedAt, &s.UpdatedAt); err != nil {
 		return err
 	}
-	defer stmt.Close()
+	s.Tags = []string{}
+	if tags != "" {
+		s.Tags = strings.Split(tags, ",")
+	}
+	return nil
+}
 
-	return stmt.QueryRow(s.ID).Scan(&s.Name)
+// execer is a database or a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
-// UpdateServer is used to modify a specific server.
-func (s *Server) UpdateServer(db *sql.DB) (res sql.Result, err error) {
+// setTags replaces the server's tags.
+func (s *Server) setTags(ctx context.Context, db execer) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM server_tags WHERE server_id = ?", s.ID); err != nil {
+		return err
+	}
+	for _, tag := range s.Tags {
+		if _, err := db.ExecContext(ctx, "INSERT INTO server_tags (server_id, tag) VALUES (?, ?)", s.ID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	stmt, err := db.Prepare("UPDATE servers SET name = ? WHERE id = ?")
+// inTransaction calls f in a transaction, committed if f succeeds.
+func inTransaction(ctx context.Context, db *sql.DB, f func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetServer returns a single specified server.
+func (s *Server) GetServer(ctx context.Context, db *sql.DB) (err error) {
+
+	query := selectServers + " WHERE s.id = ?" + groupServers
+	ctx, span := tracing.StartQuery(ctx, "SELECT", "servers", query)
+	defer func() {
+		// Not finding the server is not a database error
+		if err == sql.ErrNoRows {
+			tracing.End(span, nil)
+		} else {
+			tracing.End(span, err)
+		}
+	}()
+
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return err
 	}
 	defer stmt.Close()
 
-	return stmt.Exec(s.Name, s.ID)
+	return s.scan(stmt.QueryRowContext(ctx, s.ID))
+}
+
+// UpdateServer is used to modify a specific server, and replace its tags. It
+// returns sql.ErrNoRows if there is no such server.
+func (s *Server) UpdateServer(ctx context.Context, db *sql.DB) (res sql.Result, err error) {
+
+	// Changing only the tags still updates the server
+	query := "UPDATE servers SET name = ?, status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?"
+	ctx, span := tracing.StartQuery(ctx, "UPDATE", "servers", query)
+	defer func() {
+		// Not finding the server is not a database error
+		if err == sql.ErrNoRows {
+			tracing.End(span, nil)
+		} else {
+			tracing.End(span, err)
+		}
+	}()
+
+	err = inTransaction(ctx, db, func(tx *sql.Tx) error {
+		// Lock the server, if it still exists
+		var id int64
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM servers WHERE id = ? FOR UPDATE", s.ID).Scan(&id); err != nil {
+			return err
+		}
+		if res, err = tx.ExecContext(ctx, query, s.Name, s.Status, s.ID); err != nil {
+			return err
+		}
+		return s.setTags(ctx, tx)
+	})
+	return res, err
+}
+
+// ErrTooManyTags is returned by AddTags if the server would have too many tags.
+var ErrTooManyTags = errors.New("servers: too many tags")
+
+// AddTags adds tags to a specific server, keeping those it has, unless it would
+// then have more than maxTags. Unlike UpdateServer, it is safe for several clients
+// to add tags at once. It returns sql.ErrNoRows if there is no such server.
+func (s *Server) AddTags(ctx context.Context, db *sql.DB, tags []string, maxTags int) (err error) {
+
+	query := "INSERT INTO server_tags (server_id, tag) VALUES (?, ?) ON DUPLICATE KEY UPDATE tag = tag"
+	ctx, span := tracing.StartQuery(ctx, "INSERT", "server_tags", query)
+	defer func() {
+		// Neither is not finding the server, or too many tags, a database error
+		if err == sql.ErrNoRows || err == ErrTooManyTags {
+			tracing.End(span, nil)
+		} else {
+			tracing.End(span, err)
+		}
+	}()
+
+	return inTransaction(ctx, db, func(tx *sql.Tx) error {
+		// Lock the server, if it still exists
+		var id int64
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM servers WHERE id = ? FOR UPDATE", s.ID).Scan(&id); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if _, err := tx.ExecContext(ctx, query, s.ID, tag); err != nil {
+				return err
+			}
+		}
+		var count int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM server_tags WHERE server_id = ?", s.ID).Scan(&count); err != nil {
+			return err
+		}
+		if count > maxTags {
+			return ErrTooManyTags
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE servers SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", s.ID)
+		return err
+	})
 }
 
 // DeleteServer is used to delete a specific server.
-func (s *Server) DeleteServer(db *sql.DB) (res sql.Result, err error) {
+func (s *Server) DeleteServer(ctx context.Context, db *sql.DB) (res sql.Result, err error) {
+
+	query := "DELETE FROM servers WHERE id = ?"
+	ctx, span := tracing.StartQuery(ctx, "DELETE", "servers", query)
+	defer func() { tracing.End(span, err) }()
 
-	stmt, err := db.Prepare("DELETE FROM servers WHERE id = ?")
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	return stmt.Exec(s.ID)
+	return stmt.ExecContext(ctx, s.ID)
 }
 
-// CreateServer is used to create a single server.
-func (s *Server) CreateServer(db *sql.DB) error {
+// CreateServer is used to create a single server, with its tags.
+func (s *Server) CreateServer(ctx context.Context, db *sql.DB) (err error) {
 
-	stmt, err := db.Prepare("INSERT INTO servers (name) VALUES(?)")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	query := "INSERT INTO servers (name, status) VALUES(?, ?)"
+	ctx, span := tracing.StartQuery(ctx, "INSERT", "servers", query)
+	defer func() { tracing.End(span, err) }()
 
-	res, err := stmt.Exec(s.Name)
-	if err != nil {
-		return err
-	}
+	return inTransaction(ctx, db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query, s.Name, s.Status)
+		if err != nil {
+			return err
+		}
+		if s.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		return s.setTags(ctx, tx)
+	})
+}
+
+// Orders servers may be listed in, by sort key. A leading '-' reverses the
+// order. Servers which sort equally are in order of name.
+var orders = map[string]string{
+	"name":        "name",
+	"-name":       "name DESC",
+	"created_at":  "created_at, name",
+	"-created_at": "created_at DESC, name",
+	"updated_at":  "updated_at, name",
+	"-updated_at": "updated_at DESC, name",
+}
 
-	s.ID, err = res.LastInsertId()
+// DefaultSort is the order servers are listed in if none is specified.
+const DefaultSort = "name"
 
-	return err
+// ValidSort reports whether servers may be listed in the order of the sort key.
+func ValidSort(sort string) bool {
+	_, ok := orders[sort]
+	return ok
+}
+
+// orderBy returns the ORDER BY clause for the sort key, by name if it is not valid.
+func orderBy(sort string) string {
+	if order, ok := orders[sort]; ok {
+		return order
+	}
+	return orders[DefaultSort]
 }
 
-// GetServers returns a collection of known servers.
-func GetServers(db *sql.DB, start int, count int) ([]Server, error) {
+// GetServers returns a collection of known servers, in the order of the sort key.
+func GetServers(ctx context.Context, db *sql.DB, start int, count int, sort string) (servers []Server, err error) {
 
-	stmt, err := db.Prepare("SELECT id, name FROM servers ORDER BY name LIMIT ? OFFSET ?")
+	query := selectServers + groupServers + " ORDER BY " + orderBy(sort) + " LIMIT ? OFFSET ?"
+	ctx, span := tracing.StartQuery(ctx, "SELECT", "servers", query)
+	defer func() { tracing.End(span, err) }()
+
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(count, start)
+	rows, err := stmt.QueryContext(ctx, count, start)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	servers := []Server{}
+	servers = []Server{}
+
 	for rows.Next() {
 		var s Server
-		if err := rows.Scan(&s.ID, &s.Name); err != nil {
+		if err := s.scan(rows); err != nil {
 			return nil, err
 		}
 		servers = append(servers, s)
@@ -90,25 +266,31 @@ func GetServers(db *sql.DB, start int, count int) ([]Server, error) {
 	return servers, nil
 }
 
-// SearchServers returns a collection of servers matching the search criteria.
-func SearchServers(db *sql.DB, start int, count int, name string) ([]Server, error) {
+// SearchServers returns a collection of servers matching the search criteria,
+// in the order of the sort key.
+func SearchServers(ctx context.Context, db *sql.DB, start int, count int, name string, sort string) (servers []Server, err error) {
+
+	query := selectServers + " WHERE s.name LIKE ?" + groupServers + " ORDER BY " + orderBy(sort) + " LIMIT ? OFFSET ?"
+	ctx, span := tracing.StartQuery(ctx, "SELECT", "servers", query)
+	defer func() { tracing.End(span, err) }()
 
-	stmt, err := db.Prepare("SELECT id, name FROM servers WHERE name LIKE ? ORDER BY name LIMIT ? OFFSET ?")
+	stmt, err := db.PrepareContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.Query(name, count, start)
+	rows, err := stmt.QueryContext(ctx, name, count, start)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	servers := []Server{}
+	servers = []Server{}
+
 	for rows.Next() {
 		var s Server
-		if err := rows.Scan(&s.ID, &s.Name); err != nil {
+		if err := s.scan(rows); err != nil {
 			return nil, err
 		}
 		servers = append(servers, s)
@@ -116,3 +298,53 @@ func SearchServers(db *sql.DB, start int, count int, name string) ([]Server, err
 
 	return servers, nil
 }
+
+// CountServers returns the number of known servers.
+func CountServers(ctx context.Context, db *sql.DB) (count int64, err error) {
+
+	query := "SELECT COUNT(*) FROM servers"
+	ctx, span := tracing.StartQuery(ctx, "SELECT", "servers", query)
+	defer func() { tracing.End(span, err) }()
+
+	err = db.QueryRowContext(ctx, query).Scan(&count)
+
+	return count, err
+}
+
+// CountServersByStatus returns the number of known servers with each status
+// which any server has.
+func CountServersByStatus(ctx context.Context, db *sql.DB) (counts map[string]int64, err error) {
+
+	query := "SELECT status, COUNT(*) FROM servers GROUP BY status"
+	ctx, span := tracing.StartQuery(ctx, "SELECT", "servers", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts = map[string]int64{}
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// CountMatchingServers returns the number of servers matching the search criteria.
+func CountMatchingServers(ctx context.Context, db *sql.DB, name string) (count int64, err error) {
+
+	query := "SELECT COUNT(*) FROM servers WHERE name LIKE ?"
+	ctx, span := tracing.StartQuery(ctx, "SELECT", "servers", query)
+	defer func() { tracing.End(span, err) }()
+
+	err = db.QueryRowContext(ctx, query, name).Scan(&count)
+
+	return count, err
+}
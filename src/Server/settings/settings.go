@@ -0,0 +1,171 @@
+// Package settings loads a configuration described by a table of settings.
+//
+// Each setting is taken from, in increasing order of precedence: its default,
+// a YAML config file, its environment variable, and its command-line flag.
+// Secrets have no flag, so as not to appear in process listings, and their
+// environment variables are looked up with the secrets package, so they may
+// also come from files.
+package settings
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	// local import
+	"admin-server/secrets"
+
+	// GitHub packages
+	"gopkg.in/yaml.v3"
+)
+
+// A Setting is a single configurable value.
+type Setting struct {
+	Flag   string // no flag for secrets
+	Env    string
+	Secret bool
+	Value  flag.Value
+	Usage  string
+}
+
+// Flag is a setting with a command-line flag and an environment variable.
+func Flag(name, env string, value flag.Value, usage string) Setting {
+	return Setting{Flag: name, Env: env, Value: value, Usage: usage}
+}
+
+// Secret is a setting with only an environment variable, which is redacted
+// when printed.
+func Secret(env string, value flag.Value) Setting {
+	return Setting{Env: env, Secret: true, Value: value}
+}
+
+// A Config is a pointer to a configuration struct, which lists its settings.
+type Config interface {
+	Settings() []Setting
+}
+
+// Load fills in c, which holds the defaults, from the command-line arguments
+// (excluding the program name) and the environment. The config file is named
+// by the -config flag or CONFIG_FILE.
+func Load(name string, args []string, c Config) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML config file")
+	AddFlags(fs, c)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument '%s'", fs.Arg(0))
+	}
+
+	if *configFile != "" {
+		if err := LoadFile(*configFile, c); err != nil {
+			return err
+		}
+	}
+	return Apply(fs, c)
+}
+
+// AddFlags adds a flag to the flag set for each setting that has one.
+func AddFlags(fs *flag.FlagSet, c Config) {
+	for _, s := range c.Settings() {
+		if s.Flag == "" {
+			continue
+		}
+		if b, ok := s.Value.(boolValue); ok {
+			fs.Bool(s.Flag, *b.p, s.Usage+" (env "+s.Env+")")
+		} else {
+			fs.String(s.Flag, s.Value.String(), s.Usage+" (env "+s.Env+")")
+		}
+	}
+}
+
+// LoadFile overrides the settings present in a YAML config file. A file
+// containing secrets must not be accessible by group or others.
+func LoadFile(path string, c Config) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fromFile := reflect.New(reflect.TypeOf(c).Elem()).Interface().(Config)
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(fromFile); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	// Only settings present in the file override the defaults
+	dec = yaml.NewDecoder(bytes.NewReader(b))
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	for _, s := range fromFile.Settings() {
+		if s.Secret && s.Value.String() != "" && info.Mode().Perm()&0077 != 0 {
+			return fmt.Errorf("%s contains secrets so must not be accessible by group or others (mode %v)", path, info.Mode().Perm())
+		}
+	}
+	return nil
+}
+
+// Apply overrides settings with their environment variables, and then with
+// the flags set on the (parsed) flag set.
+func Apply(fs *flag.FlagSet, c Config) error {
+	provider, err := secrets.FromEnvironment()
+	if err != nil {
+		return err
+	}
+	for _, s := range c.Settings() {
+		var v string
+		var ok bool
+		if s.Secret {
+			if v, ok, err = provider.Lookup(s.Env); err != nil {
+				return err
+			}
+		} else {
+			v, ok = os.LookupEnv(s.Env)
+		}
+		if ok {
+			if err := s.Value.Set(v); err != nil {
+				return fmt.Errorf("%s: %v", s.Env, err)
+			}
+		}
+	}
+
+	flags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { flags[f.Name] = f.Value.String() })
+	for _, s := range c.Settings() {
+		if v, ok := flags[s.Flag]; ok && s.Flag != "" {
+			if err := s.Value.Set(v); err != nil {
+				return fmt.Errorf("-%s: %v", s.Flag, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Print writes the configuration as YAML, with any secrets redacted.
+func Print(w io.Writer, c Config) error {
+	redacted := reflect.New(reflect.TypeOf(c).Elem())
+	redacted.Elem().Set(reflect.ValueOf(c).Elem())
+	for _, s := range redacted.Interface().(Config).Settings() {
+		if s.Secret && s.Value.String() != "" {
+			s.Value.Set("REDACTED")
+		}
+	}
+	b, err := yaml.Marshal(redacted.Interface())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,136 @@
+package settings
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Name     string        `yaml:"name"`
+	Verbose  bool          `yaml:"verbose"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Tags     []string      `yaml:"tags"`
+	Password string        `yaml:"password"`
+}
+
+func (c *testConfig) Settings() []Setting {
+	return []Setting{
+		Flag("name", "TEST_NAME", String(&c.Name), "name"),
+		Flag("verbose", "TEST_VERBOSE", Bool(&c.Verbose), "say more"),
+		Flag("timeout", "TEST_TIMEOUT", Duration(&c.Timeout), "timeout"),
+		Flag("tags", "TEST_TAGS", List(&c.Tags), "tags"),
+		Secret("TEST_PASSWORD", String(&c.Password)),
+	}
+}
+
+func writeFile(t *testing.T, contents string, mode os.FileMode) string {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := ioutil.WriteFile(path, []byte(contents), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeFile(t, "name: file\ntimeout: 5s\ntags: [a]\n", 0644)
+	t.Setenv("TEST_TIMEOUT", "1m")
+	t.Setenv("TEST_TAGS", "b, c,")
+
+	c := testConfig{Name: "default"}
+	if err := Load("test", []string{"-config", path, "-verbose"}, &c); err != nil {
+		t.Fatalf("Error on Load: %v", err)
+	}
+	if c.Name != "file" || !c.Verbose || c.Timeout != time.Minute || strings.Join(c.Tags, ",") != "b,c" {
+		t.Errorf("Unexpected configuration: %+v", c)
+	}
+
+	if err := Load("test", []string{"extra"}, &c); err == nil {
+		t.Error("Unexpected argument accepted!")
+	}
+	if err := Load("test", []string{"-timeout", "soon"}, &c); err == nil {
+		t.Error("Invalid duration accepted!")
+	}
+}
+
+func TestLoadFileSecrets(t *testing.T) {
+	var c testConfig
+	if err := LoadFile(writeFile(t, "password: secret\n", 0644), &c); err == nil {
+		t.Error("World-readable secrets accepted!")
+	}
+	if err := LoadFile(writeFile(t, "password: secret\n", 0600), &c); err != nil {
+		t.Errorf("Private secrets refused: %v", err)
+	}
+	if err := LoadFile(writeFile(t, "pasword: secret\n", 0600), &c); err == nil {
+		t.Error("Unknown setting accepted!")
+	}
+}
+
+func TestAddFlags(t *testing.T) {
+	var c testConfig
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	AddFlags(fs, &c)
+	if fs.Lookup("verbose") == nil || fs.Lookup("name") == nil {
+		t.Error("Expected flags for verbose and name")
+	}
+	if err := fs.Parse([]string{"-verbose", "-name", "flag"}); err != nil {
+		t.Fatalf("Error on Parse: %v", err)
+	}
+	if err := Apply(fs, &c); err != nil {
+		t.Fatalf("Error on Apply: %v", err)
+	}
+	if !c.Verbose || c.Name != "flag" {
+		t.Errorf("Expected flags to be applied. Got %+v", c)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	c := testConfig{Name: "name", Password: "secret"}
+
+	var b bytes.Buffer
+	if err := Print(&b, &c); err != nil {
+		t.Fatalf("Error on Print: %v", err)
+	}
+	if strings.Contains(b.String(), "secret") || !strings.Contains(b.String(), "REDACTED") {
+		t.Errorf("Expected password to be redacted. Got:\n%s", b.String())
+	}
+	if c.Password != "secret" {
+		t.Error("Print changed the configuration!")
+	}
+}
+
+func TestValidator(t *testing.T) {
+	var v Validator
+	if v.Err() != nil {
+		t.Error("No problems, but an error!")
+	}
+
+	v.Port("port (PORT)", "http")
+	v.Port("ok (OK)", "8080")
+	v.Required("host (HOST)", "")
+	v.File("cert (CERT)", "/nonexistent/cert.pem")
+	v.Directory("dir (DIR)", "/nonexistent")
+	v.Directory("ok (OK)", "")
+	v.Positive("timeout (TIMEOUT)", 0)
+	v.URL("endpoint (ENDPOINT)", "ftp://example.com", "http", "https")
+	v.URL("ok (OK)", "https://example.com", "http", "https")
+
+	err, ok := v.Err().(Errors)
+	if !ok || len(err) != 6 {
+		t.Fatalf("Expected 6 problems. Got %v", v.Err())
+	}
+	for _, expected := range []string{"port (PORT)", "host (HOST)", "cert (CERT)", "dir (DIR)", "timeout (TIMEOUT)",
+		"endpoint (ENDPOINT) must be an http or https URL"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error for %s. Got:\n%v", expected, err)
+		}
+	}
+}
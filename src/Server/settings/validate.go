@@ -0,0 +1,86 @@
+package settings
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors lists everything wrong with a configuration.
+type Errors []string
+
+func (e Errors) Error() string {
+	return "invalid configuration:\n    " + strings.Join(e, "\n    ")
+}
+
+// A Validator collects everything wrong with a configuration. Each setting is
+// named as in the config file, followed by its environment variable, for
+// example "tls.cert (TLS_CERT)".
+type Validator struct {
+	errs Errors
+}
+
+// Errorf records a problem.
+func (v *Validator) Errorf(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Sprintf(format, args...))
+}
+
+// Port checks for a port number.
+func (v *Validator) Port(name, value string) {
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		v.Errorf("%s must be a port number, not '%s'", name, value)
+	}
+}
+
+// Required checks that a setting is not blank.
+func (v *Validator) Required(name, value string) {
+	if value == "" {
+		v.Errorf("%s is required", name)
+	}
+}
+
+// File checks for a file which exists.
+func (v *Validator) File(name, path string) {
+	if path == "" {
+		v.Errorf("%s is required", name)
+	} else if _, err := os.Stat(path); err != nil {
+		v.Errorf("%s: %v", name, err)
+	}
+}
+
+// Directory checks an optional directory.
+func (v *Validator) Directory(name, dir string) {
+	if info, err := os.Stat(dir); dir != "" && (err != nil || !info.IsDir()) {
+		v.Errorf("%s: '%s' is not a directory", name, dir)
+	}
+}
+
+// Positive checks for a duration greater than zero.
+func (v *Validator) Positive(name string, d time.Duration) {
+	if d <= 0 {
+		v.Errorf("%s must be positive, not %v", name, d)
+	}
+}
+
+// URL checks for an absolute URL with one of the given schemes.
+func (v *Validator) URL(name, value string, schemes ...string) {
+	if u, err := url.Parse(value); err == nil && u.Host != "" {
+		for _, scheme := range schemes {
+			if u.Scheme == scheme {
+				return
+			}
+		}
+	}
+	v.Errorf("%s must be an %s URL, not '%s'", name, strings.Join(schemes, " or "), value)
+}
+
+// Err returns the problems found, if there were any.
+func (v *Validator) Err() error {
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
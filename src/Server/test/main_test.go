@@ -1,42 +1,79 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	// local import
 	"admin-server/application"
+	"admin-server/config"
+	"admin-server/contract"
+	"admin-server/identity"
+	"admin-server/throttle"
 )
 
 var app application.App
 
 var authUser, authPassword string
 
+const identityKey = "test identity signing key"
+
 func TestMain(m *testing.M) {
-	authUser = os.Getenv("AUTH_USER")
-	authPassword = os.Getenv("AUTH_PASSWORD")
+	cfg, err := config.Load("test", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.IdentitySigningKey = identityKey
+	authUser = cfg.Auth.User
+	authPassword = cfg.Auth.Password
 	app = application.App{}
-	app.Initialize(
-		os.Getenv("MYSQL_HOST"),
-		os.Getenv("MYSQL_PORT"),
-		os.Getenv("MYSQL_USER"),
-		os.Getenv("MYSQL_PASSWORD"),
-		os.Getenv("MYSQL_DB"),
-		authUser,
-		authPassword)
-	ensureTablesExist()
+	app.Initialize(cfg)
+	if err := app.Connect(context.Background()); err != nil {
+		log.Fatal(err)
+	}
 	code := m.Run()
 	clearTables()
 	os.Exit(code)
 }
 
+func TestHealth(t *testing.T) {
+	req, err := http.NewRequest("GET", "/healthz", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest: %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
+
+func TestReady(t *testing.T) {
+	req, err := http.NewRequest("GET", "/readyz", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest: %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["status"] != "ready" {
+		t.Errorf("Expected the status to be 'ready'. Got '%v'", m["status"])
+	}
+}
+
 func TestEmptyTables(t *testing.T) {
 	clearTables()
 
@@ -76,17 +113,21 @@ func TestGetNonExistentServer(t *testing.T) {
 
 	checkResponseCode(t, http.StatusNotFound, response.Code)
 
-	var m map[string]string
+	if ct := response.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected a problem+json response. Got '%s'", ct)
+	}
+
+	var m map[string]interface{}
 	json.Unmarshal(response.Body.Bytes(), &m)
-	if m["error"] != "Server not found" {
-		t.Errorf("Expected the 'error' key of the response to be set to 'Server not found'. Got '%s'", m["error"])
+	if m["code"] != "not_found" || m["detail"] != "Server not found" {
+		t.Errorf("Expected the 'code' and 'detail' keys to be 'not_found' and 'Server not found'. Got '%v' and '%v'", m["code"], m["detail"])
 	}
 }
 
 func TestCreateServerNoCredentials(t *testing.T) {
 	clearTables()
 
-	payload := []byte(`{"name":"test server"}`)
+	payload := []byte(`{"name":"test.example.com"}`)
 
 	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
 	if err != nil {
@@ -100,7 +141,7 @@ func TestCreateServerNoCredentials(t *testing.T) {
 func TestCreateServerWithCredentials(t *testing.T) {
 	clearTables()
 
-	payload := []byte(`{"name":"test server"}`)
+	payload := []byte(`{"name":"test.example.com"}`)
 
 	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
 	if err != nil {
@@ -114,8 +155,8 @@ func TestCreateServerWithCredentials(t *testing.T) {
 	var m map[string]interface{}
 	json.Unmarshal(response.Body.Bytes(), &m)
 
-	if m["name"] != "test server" {
-		t.Errorf("Expected server name to be 'test server'. Got '%v'", m["name"])
+	if m["name"] != "test.example.com" {
+		t.Errorf("Expected server name to be 'test.example.com'. Got '%v'", m["name"])
 	}
 
 	// the id is compared to 1.0 because JSON unmarshaling converts numbers to
@@ -128,7 +169,7 @@ func TestCreateServerWithCredentials(t *testing.T) {
 func TestCreateDuplicateServerWithCredentials(t *testing.T) {
 	clearTables()
 
-	payload := []byte(`{"name":"test server"}`)
+	payload := []byte(`{"name":"test.example.com"}`)
 
 	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
 	if err != nil {
@@ -151,6 +192,62 @@ func TestCreateDuplicateServerWithCredentials(t *testing.T) {
 	checkResponseCode(t, http.StatusConflict, response.Code)
 }
 
+func TestCreateInvalidServer(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"-bad_host"}`)
+
+	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest: %s", err)
+	}
+	req.SetBasicAuth(authUser, authPassword)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+
+	var m struct {
+		Code   string
+		Errors []map[string]string
+	}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m.Code != "validation_failed" || len(m.Errors) != 2 {
+		t.Errorf("Expected 'validation_failed' with 2 errors. Got '%s' with %v", m.Code, m.Errors)
+	}
+}
+
+func TestCreateServerNormalizesName(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"Test.Example.COM."}`)
+
+	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest: %s", err)
+	}
+	req.SetBasicAuth(authUser, authPassword)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	if m["name"] != "test.example.com" {
+		t.Errorf("Expected server name to be 'test.example.com'. Got '%v'", m["name"])
+	}
+
+	// The normalized name is a duplicate
+	payload = []byte(`{"name":"test.example.com"}`)
+	req, err = http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on 2nd http.NewRequest: %s", err)
+	}
+	req.SetBasicAuth(authUser, authPassword)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+}
+
 func TestGetServer(t *testing.T) {
 	clearTables()
 	addServers(1)
@@ -162,6 +259,15 @@ func TestGetServer(t *testing.T) {
 	response := executeRequest(req)
 
 	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var m map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &m)
+	for _, field := range []string{"created_at", "updated_at"} {
+		v, _ := m[field].(string)
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			t.Errorf("Expected %s to be a timestamp. Got '%v'", field, m[field])
+		}
+	}
 }
 
 func TestGetServers(t *testing.T) {
@@ -184,6 +290,29 @@ func TestGetServers(t *testing.T) {
 	}
 }
 
+func TestGetServersSorted(t *testing.T) {
+	clearTables()
+	addServers(3)
+
+	req, err := http.NewRequest("GET", "/v1/servers?count=2&sort=-name", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest: %s", err)
+	}
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var mm []map[string]interface{}
+	json.Unmarshal(response.Body.Bytes(), &mm)
+
+	if len(mm) != 2 || mm[0]["name"] != "Server 3" || mm[1]["name"] != "Server 2" {
+		t.Errorf("Expected 'Server 3' and 'Server 2'. Got '%v'", mm)
+	}
+	if total := response.Header().Get("X-Total-Count"); total != "3" {
+		t.Errorf("Expected a total of '3' servers. Got '%s'", total)
+	}
+}
+
 func TestUpdatePutServerNoCredentials(t *testing.T) {
 	clearTables()
 	addServers(1)
@@ -196,7 +325,7 @@ func TestUpdatePutServerNoCredentials(t *testing.T) {
 	var originalServer map[string]interface{}
 	json.Unmarshal(response.Body.Bytes(), &originalServer)
 
-	payload := []byte(`{"name":"test server - updated"}`)
+	payload := []byte(`{"name":"updated.example.com"}`)
 
 	req, err = http.NewRequest("PUT", "/v1/servers/1", bytes.NewBuffer(payload))
 	if err != nil {
@@ -219,7 +348,7 @@ func TestUpdatePutServerWithCredentials(t *testing.T) {
 	var originalServer map[string]interface{}
 	json.Unmarshal(response.Body.Bytes(), &originalServer)
 
-	payload := []byte(`{"name":"test server - updated"}`)
+	payload := []byte(`{"name":"updated.example.com"}`)
 
 	req, err = http.NewRequest("PUT", "/v1/servers/1", bytes.NewBuffer(payload))
 	if err != nil {
@@ -253,7 +382,7 @@ func TestUpdatePatchServerNoCredentials(t *testing.T) {
 	var originalServer map[string]interface{}
 	json.Unmarshal(response.Body.Bytes(), &originalServer)
 
-	payload := []byte(`{"name":"test server - updated"}`)
+	payload := []byte(`{"name":"updated.example.com"}`)
 
 	req, err = http.NewRequest("PATCH", "/v1/servers/1", bytes.NewBuffer(payload))
 	if err != nil {
@@ -276,7 +405,7 @@ func TestUpdatePatchServerWithCredentials(t *testing.T) {
 	var originalServer map[string]interface{}
 	json.Unmarshal(response.Body.Bytes(), &originalServer)
 
-	payload := []byte(`{"name":"test server - updated"}`)
+	payload := []byte(`{"name":"updated.example.com"}`)
 
 	req, err = http.NewRequest("PATCH", "/v1/servers/1", bytes.NewBuffer(payload))
 	if err != nil {
@@ -346,6 +475,369 @@ func TestDeleteServerWithCredentials(t *testing.T) {
 	checkResponseCode(t, http.StatusNotFound, response.Code)
 }
 
+func TestServerStatusAndTags(t *testing.T) {
+	clearTables()
+
+	send := func(method, path, payload string, expected int) map[string]interface{} {
+		req, err := http.NewRequest(method, path, bytes.NewBufferString(payload))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (%s): %s", method, err)
+		}
+		req.SetBasicAuth(authUser, authPassword)
+		response := executeRequest(req)
+		checkResponseCode(t, expected, response.Code)
+
+		var m map[string]interface{}
+		json.Unmarshal(response.Body.Bytes(), &m)
+		return m
+	}
+
+	m := send("POST", "/v1/servers", `{"name":"db.example.com","tags":["Rack-12","db"]}`, http.StatusCreated)
+	if m["status"] != "active" || fmt.Sprint(m["tags"]) != "[db rack-12]" {
+		t.Errorf("Expected an active server tagged 'db' and 'rack-12'. Got '%v'", m)
+	}
+
+	// PATCH changes only what is given, and PUT leaves the status and tags alone
+	m = send("PATCH", "/v1/servers/1", `{"status":"maintenance"}`, http.StatusOK)
+	if m["name"] != "db.example.com" || m["status"] != "maintenance" || fmt.Sprint(m["tags"]) != "[db rack-12]" {
+		t.Errorf("Expected only the status to change. Got '%v'", m)
+	}
+	m = send("PUT", "/v1/servers/1", `{"name":"db-01.example.com"}`, http.StatusOK)
+	if m["status"] != "maintenance" || fmt.Sprint(m["tags"]) != "[db rack-12]" {
+		t.Errorf("Expected the status and tags to be kept. Got '%v'", m)
+	}
+	m = send("PATCH", "/v1/servers/1", `{"tags":[]}`, http.StatusOK)
+	if fmt.Sprint(m["tags"]) != "[]" {
+		t.Errorf("Expected no tags. Got '%v'", m)
+	}
+
+	m = send("PATCH", "/v1/servers/1", `{"status":"retired","tags":["a b"]}`, http.StatusUnprocessableEntity)
+	if errs, _ := m["errors"].([]interface{}); len(errs) != 2 {
+		t.Errorf("Expected errors for the status and tags. Got '%v'", m)
+	}
+
+	// Adding tags keeps those the server has, up to the limit
+	send("PATCH", "/v1/servers/1", `{"tags":["db"]}`, http.StatusOK)
+	m = send("POST", "/v1/servers/1/tags", `{"tags":["Web","db"]}`, http.StatusOK)
+	if fmt.Sprint(m["tags"]) != "[db web]" {
+		t.Errorf("Expected the tags 'db' and 'web'. Got '%v'", m)
+	}
+	var many []string
+	for i := 0; i < 19; i++ {
+		many = append(many, fmt.Sprintf(`"t%d"`, i))
+	}
+	m = send("POST", "/v1/servers/1/tags", `{"tags":[`+strings.Join(many, ",")+`]}`, http.StatusUnprocessableEntity)
+	if m["code"] != "validation_failed" {
+		t.Errorf("Expected too many tags to fail validation. Got '%v'", m)
+	}
+	send("POST", "/v1/servers/1/tags", `{"tags":[]}`, http.StatusUnprocessableEntity)
+	send("POST", "/v1/servers/2/tags", `{"tags":["db"]}`, http.StatusNotFound)
+}
+
+func TestServersMetric(t *testing.T) {
+	clearTables()
+
+	for _, payload := range []string{`{"name":"a.example.com"}`, `{"name":"b.example.com","status":"maintenance"}`} {
+		req, _ := http.NewRequest("POST", "/v1/servers", bytes.NewBufferString(payload))
+		req.SetBasicAuth(authUser, authPassword)
+		checkResponseCode(t, http.StatusCreated, executeRequest(req).Code)
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	body := executeRequest(req).Body.String()
+	for _, expected := range []string{
+		`sadmin_servers{status="active"} 1`,
+		`sadmin_servers{status="maintenance"} 1`,
+		`sadmin_servers{status="decommissioned"} 0`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metric '%s'", expected)
+		}
+	}
+}
+
+func TestModifyDeletedServer(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"gone.example.com"}`)
+	for _, method := range []string{"PUT", "PATCH", "DELETE"} {
+		req, err := http.NewRequest(method, "/v1/servers/1", bytes.NewBuffer(payload))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (%s): %s", method, err)
+		}
+		req.SetBasicAuth(authUser, authPassword)
+		response := executeRequest(req)
+		checkResponseCode(t, http.StatusNotFound, response.Code)
+	}
+}
+
+func TestEventsOnChange(t *testing.T) {
+	clearTables()
+
+	srv := httptest.NewServer(app.Router)
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/v1/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error on GET /v1/events: %s", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	stream := bufio.NewReader(resp.Body)
+
+	for _, tc := range []struct {
+		method, path, payload, event string
+	}{
+		{"POST", "/v1/servers", `{"name":"watched.example.com"}`, "server.created"},
+		{"PATCH", "/v1/servers/1", `{"status":"maintenance"}`, "server.modified"},
+		{"DELETE", "/v1/servers/1", ``, "server.deleted"},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.payload))
+		req.SetBasicAuth(authUser, authPassword)
+		executeRequest(req)
+
+		// Skip the reconnection delay and any keep-alive comments
+		var event string
+		for event == "" {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Error reading the stream: %s", err)
+			}
+			if strings.HasPrefix(line, "event: ") {
+				event = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+			}
+		}
+		if event != tc.event {
+			t.Errorf("%s %s - Expected event %s. Got %s", tc.method, tc.path, tc.event, event)
+		}
+	}
+}
+
+func TestCreateServerWithDelegatedIdentity(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"test.example.com"}`)
+
+	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (viewer): %s", err)
+	}
+	req.SetBasicAuth(authUser, authPassword)
+	req.Header.Set(identity.Header, signIdentity(t, "jdoe", identity.RoleViewer))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	req, err = http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (editor): %s", err)
+	}
+	req.SetBasicAuth(authUser, authPassword)
+	req.Header.Set(identity.Header, signIdentity(t, "jdoe", identity.RoleEditor))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+}
+
+func TestCreateServerWithForgedIdentity(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"test.example.com"}`)
+
+	token, err := identity.Sign([]byte("not the key"), "mallory", identity.RoleAdmin, time.Minute)
+	if err != nil {
+		t.Errorf("Error on identity.Sign: %s", err)
+	}
+
+	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest: %s", err)
+	}
+	req.SetBasicAuth(authUser, authPassword)
+	req.Header.Set(identity.Header, token)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestDeleteServerWithDelegatedIdentity(t *testing.T) {
+	clearTables()
+	addServers(1)
+
+	req, err := http.NewRequest("DELETE", "/v1/servers/1", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (editor): %s", err)
+	}
+	req.SetBasicAuth(authUser, authPassword)
+	req.Header.Set(identity.Header, signIdentity(t, "jdoe", identity.RoleEditor))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusForbidden, response.Code)
+
+	req, err = http.NewRequest("DELETE", "/v1/servers/1", nil)
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (admin): %s", err)
+	}
+	req.SetBasicAuth(authUser, authPassword)
+	req.Header.Set(identity.Header, signIdentity(t, "jdoe", identity.RoleAdmin))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+}
+
+func signIdentity(t *testing.T, user, role string) string {
+	token, err := identity.Sign([]byte(identityKey), user, role, time.Minute)
+	if err != nil {
+		t.Errorf("Error on identity.Sign: %s", err)
+	}
+	return token
+}
+
+func TestRepeatedAuthFailures(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"test.example.com"}`)
+
+	// Use an address and account of our own, so as not to lock out other tests
+	for i := 1; i < throttle.FreeFailures; i++ {
+		req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (failure %d): %s", i, err)
+		}
+		req.RemoteAddr = "192.0.2.99:1234"
+		req.SetBasicAuth("intruder", "guess")
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusUnauthorized, response.Code)
+	}
+
+	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (lockout): %s", err)
+	}
+	req.RemoteAddr = "192.0.2.99:1234"
+	req.SetBasicAuth("intruder", "guess")
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	// Now locked out, even with the right password
+	req, err = http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (locked out): %s", err)
+	}
+	req.RemoteAddr = "192.0.2.99:1234"
+	req.SetBasicAuth(authUser, authPassword)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusTooManyRequests, response.Code)
+
+	if response.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After to be '1'. Got '%s'", response.Header().Get("Retry-After"))
+	}
+}
+
+func TestAuthFailuresDoNotLockOutAccount(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name":"test.example.com"}`)
+
+	// Someone guessing at the real account locks it out for failures...
+	for i := 0; i < throttle.FreeFailures; i++ {
+		req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+		if err != nil {
+			t.Errorf("Error on http.NewRequest (failure %d): %s", i+1, err)
+		}
+		req.RemoteAddr = "192.0.2.100:1234"
+		req.SetBasicAuth(authUser, "guess")
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusUnauthorized, response.Code)
+	}
+
+	// ...but not for the right password from elsewhere
+	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (elsewhere): %s", err)
+	}
+	req.RemoteAddr = "192.0.2.101:1234"
+	req.SetBasicAuth(authUser, authPassword)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	// Another address guessing at the locked out account is locked out itself
+	req, err = http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (another guess): %s", err)
+	}
+	req.RemoteAddr = "192.0.2.102:1234"
+	req.SetBasicAuth(authUser, "guess")
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusUnauthorized, response.Code)
+
+	req, err = http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Errorf("Error on http.NewRequest (locked out): %s", err)
+	}
+	req.RemoteAddr = "192.0.2.102:1234"
+	req.SetBasicAuth(authUser, authPassword)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusTooManyRequests, response.Code)
+}
+
+// TestResponsesMatchSpec checks successful responses against the OpenAPI
+// document. The application package checks the others without a database.
+func TestResponsesMatchSpec(t *testing.T) {
+	clearTables()
+
+	b, err := os.ReadFile("../application/openapi.yaml")
+	if err != nil {
+		t.Fatalf("Error reading openapi.yaml: %v", err)
+	}
+	spec, err := contract.Load(b)
+	if err != nil {
+		t.Fatalf("Error parsing openapi.yaml: %v", err)
+	}
+
+	for _, tc := range []struct {
+		op, path, body, contentType string
+		expected                    int
+	}{
+		{"POST /v1/servers", "/v1/servers", `{"name":"a.example.com","tags":["db"]}`, "", http.StatusCreated},
+		{"GET /v1/servers", "/v1/servers", "", "", http.StatusOK},
+		{"GET /v1/servers/{id}", "/v1/servers/1", "", "", http.StatusOK},
+		{"PUT /v1/servers/{id}", "/v1/servers/1", `{"name":"b.example.com","status":"maintenance"}`, "", http.StatusOK},
+		{"PATCH /v1/servers/{id}", "/v1/servers/1", `{"tags":["db","web"]}`, "", http.StatusOK},
+		{"POST /v1/servers/{id}/tags", "/v1/servers/1/tags", `{"tags":["rack-12"]}`, "", http.StatusOK},
+		{"POST /v1/search/servers", "/v1/search/servers", "name=%25example%25", "application/x-www-form-urlencoded", http.StatusOK},
+		{"GET /readyz", "/readyz", "", "", http.StatusOK},
+		{"GET /metrics", "/metrics", "", "", http.StatusOK},
+		{"DELETE /v1/servers/{id}", "/v1/servers/1", "", "", http.StatusOK},
+	} {
+		method := strings.Fields(tc.op)[0]
+		req, err := http.NewRequest(method, tc.path, bytes.NewBufferString(tc.body))
+		if err != nil {
+			t.Fatalf("Error on http.NewRequest: %s", err)
+		}
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		req.SetBasicAuth(authUser, authPassword)
+		response := executeRequest(req)
+
+		if response.Code != tc.expected {
+			t.Errorf("%s %s - Expected response code %d. Got %d", method, tc.path, tc.expected, response.Code)
+		}
+		for _, err := range spec.CheckResponse(tc.op, response) {
+			t.Errorf("%s %s - %s", method, tc.path, err)
+		}
+	}
+}
+
 func executeRequest(req *http.Request) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	app.Router.ServeHTTP(rr, req)
@@ -358,12 +850,6 @@ func checkResponseCode(t *testing.T, expected, actual int) {
 	}
 }
 
-func ensureTablesExist() {
-	if _, err := app.DB.Exec(serversTableCreationQuery); err != nil {
-		log.Fatal(err)
-	}
-}
-
 func clearTables() {
 	app.DB.Exec("DELETE FROM servers")
 	app.DB.Exec("ALTER TABLE servers AUTO_INCREMENT = 1")
@@ -372,7 +858,7 @@ func clearTables() {
 func TestSearch(t *testing.T) {
 	clearTables()
 
-	payload := []byte(`{"name":"test server"}`)
+	payload := []byte(`{"name":"test.example.com"}`)
 
 	req, err := http.NewRequest("POST", "/v1/servers", bytes.NewBuffer(payload))
 	if err != nil {
@@ -427,8 +913,8 @@ func TestSearch(t *testing.T) {
 	// only want the first one
 	m := mm[0]
 
-	if m["name"] != "test server" {
-		t.Errorf("3rd Post - Expected server name to be 'test server'. Got '%v'", m["name"])
+	if m["name"] != "test.example.com" {
+		t.Errorf("3rd Post - Expected server name to be 'test.example.com'. Got '%v'", m["name"])
 	}
 
 	// the id is compared to 1.0 because JSON unmarshaling converts numbers to
@@ -457,8 +943,8 @@ func TestSearch(t *testing.T) {
 	// only want the first one
 	m = mm[0]
 
-	if m["name"] != "test server" {
-		t.Errorf("4th Post - Expected server name to be 'test server'. Got '%v'", m["name"])
+	if m["name"] != "test.example.com" {
+		t.Errorf("4th Post - Expected server name to be 'test.example.com'. Got '%v'", m["name"])
 	}
 
 	// the id is compared to 1.0 because JSON unmarshaling converts numbers to
@@ -594,10 +1080,3 @@ func addServers(count int) {
 			"Server "+strconv.Itoa(i))
 	}
 }
-
-const serversTableCreationQuery = `CREATE TABLE IF NOT EXISTS servers
-(
-	id BIGINT(20) AUTO_INCREMENT,
-	name VARCHAR(50) NOT NULL UNIQUE,
-	PRIMARY KEY (id)
-)`
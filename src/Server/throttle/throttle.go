@@ -0,0 +1,189 @@
+// Package throttle limits the rate of requests per client, and slows down
+// and then locks out clients that repeatedly fail to authenticate.
+package throttle
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Failures below FreeFailures are not penalized; each one after that doubles
+// the lockout, starting from BaseLockout, up to MaxLockout.
+const (
+	FreeFailures = 3
+	BaseLockout  = time.Second
+	MaxLockout   = 15 * time.Minute
+)
+
+// How often idle entries are discarded
+const sweepInterval = time.Minute
+
+type entry struct {
+	tokens      float64
+	last        time.Time
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Throttle tracks requests and authentication failures by key, such as an
+// IP address or an account name.
+type Throttle struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	entries   map[string]*entry
+	lastSweep time.Time
+
+	// Now is the clock, replaceable for testing.
+	Now func() time.Time
+}
+
+// New returns a Throttle allowing rate requests per second per key, with bursts of up to burst.
+func New(rate float64, burst int) *Throttle {
+	return &Throttle{
+		rate:    rate,
+		burst:   float64(burst),
+		entries: map[string]*entry{},
+		Now:     time.Now,
+	}
+}
+
+func (t *Throttle) get(key string, now time.Time) *entry {
+	if now.Sub(t.lastSweep) > sweepInterval {
+		for k, e := range t.entries {
+			if now.After(e.lockedUntil) && now.Sub(e.last) > MaxLockout && now.Sub(e.lastFailure) > MaxLockout {
+				delete(t.entries, k)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	e, ok := t.entries[key]
+	if !ok {
+		e = &entry{tokens: t.burst, last: now}
+		t.entries[key] = e
+	}
+	return e
+}
+
+// Allow reports whether a request for every one of the keys may proceed and,
+// if not, how long the client should wait. A request is only counted against
+// the keys if it is allowed.
+func (t *Throttle) Allow(keys ...string) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		e := t.get(key, now)
+
+		// Refill the bucket
+		e.tokens = math.Min(t.burst, e.tokens+now.Sub(e.last).Seconds()*t.rate)
+		e.last = now
+
+		if now.Before(e.lockedUntil) {
+			if d := e.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		} else if e.tokens < 1 {
+			if d := time.Duration((1 - e.tokens) / t.rate * float64(time.Second)); d > wait {
+				wait = d
+			}
+		}
+	}
+	if wait > 0 {
+		return false, wait
+	}
+
+	for _, key := range keys {
+		t.entries[key].tokens--
+	}
+	return true, 0
+}
+
+// Locked reports how much longer any of the keys is locked out (zero if none
+// is), without counting a request against them.
+func (t *Throttle) Locked(keys ...string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		if e, ok := t.entries[key]; ok && now.Before(e.lockedUntil) {
+			if d := e.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// Lock locks out each of the keys for at least d.
+func (t *Throttle) Lock(d time.Duration, keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.Now()
+	for _, key := range keys {
+		if e := t.get(key, now); now.Add(d).After(e.lockedUntil) {
+			e.lockedUntil = now.Add(d)
+		}
+	}
+}
+
+// Fail records an authentication failure against each of the keys, and
+// returns the resulting lockout (zero if there is none).
+func (t *Throttle) Fail(keys ...string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.Now()
+	var lockout time.Duration
+	for _, key := range keys {
+		e := t.get(key, now)
+
+		// Failures are forgotten once they are old enough
+		if now.Sub(e.lastFailure) > MaxLockout {
+			e.failures = 0
+		}
+		e.failures++
+		e.lastFailure = now
+
+		if e.failures >= FreeFailures {
+			d := MaxLockout
+			if shift := e.failures - FreeFailures; shift < 20 {
+				d = BaseLockout << uint(shift)
+				if d > MaxLockout {
+					d = MaxLockout
+				}
+			}
+			e.lockedUntil = now.Add(d)
+			if d > lockout {
+				lockout = d
+			}
+		}
+	}
+	return lockout
+}
+
+// Succeed clears the authentication failures of each of the keys.
+func (t *Throttle) Succeed(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := t.entries[key]; ok {
+			e.failures = 0
+			e.lockedUntil = time.Time{}
+		}
+	}
+}
+
+// RetryAfter formats a wait as whole seconds, for a Retry-After header.
+func RetryAfter(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
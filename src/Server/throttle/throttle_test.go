@@ -0,0 +1,108 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestThrottle(rate float64, burst int) (*Throttle, *time.Time) {
+	now := time.Now()
+	t := New(rate, burst)
+	t.Now = func() time.Time { return now }
+	return t, &now
+}
+
+func TestRateLimit(t *testing.T) {
+	th, now := newTestThrottle(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := th.Allow("ip:127.0.0.1"); !ok {
+			t.Errorf("Request %d within burst refused!", i+1)
+		}
+	}
+	ok, wait := th.Allow("ip:127.0.0.1")
+	if ok || wait != time.Second {
+		t.Errorf("Expected to wait 1s. Got %v %v", ok, wait)
+	}
+
+	// Other keys are unaffected
+	if ok, _ := th.Allow("ip:127.0.0.2"); !ok {
+		t.Error("Request from another IP refused!")
+	}
+
+	*now = now.Add(time.Second)
+	if ok, _ := th.Allow("ip:127.0.0.1"); !ok {
+		t.Error("Request after refill refused!")
+	}
+}
+
+func TestProgressiveLockout(t *testing.T) {
+	th, now := newTestThrottle(100, 100)
+
+	for i := 1; i < FreeFailures; i++ {
+		if d := th.Fail("user:jdoe"); d != 0 {
+			t.Errorf("Failure %d locked out for %v!", i, d)
+		}
+	}
+	if d := th.Fail("user:jdoe"); d != BaseLockout {
+		t.Errorf("Expected lockout of %v. Got %v", BaseLockout, d)
+	}
+	if d := th.Fail("user:jdoe"); d != 2*BaseLockout {
+		t.Errorf("Expected lockout of %v. Got %v", 2*BaseLockout, d)
+	}
+	if ok, wait := th.Allow("ip:127.0.0.1", "user:jdoe"); ok || wait != 2*BaseLockout {
+		t.Errorf("Expected to wait %v. Got %v %v", 2*BaseLockout, ok, wait)
+	}
+
+	for i := 0; i < 30; i++ {
+		th.Fail("user:jdoe")
+	}
+	if ok, wait := th.Allow("user:jdoe"); ok || wait != MaxLockout {
+		t.Errorf("Expected to wait %v. Got %v %v", MaxLockout, ok, wait)
+	}
+
+	*now = now.Add(MaxLockout)
+	if ok, _ := th.Allow("user:jdoe"); !ok {
+		t.Error("Request after lockout refused!")
+	}
+	th.Succeed("user:jdoe")
+	if d := th.Fail("user:jdoe"); d != 0 {
+		t.Errorf("Failure after success locked out for %v!", d)
+	}
+}
+
+func TestLocked(t *testing.T) {
+	th, now := newTestThrottle(1, 1)
+
+	if d := th.Locked("ip:127.0.0.1"); d != 0 {
+		t.Errorf("Unknown key locked out for %v!", d)
+	}
+	for i := 0; i < FreeFailures; i++ {
+		th.Fail("user:jdoe")
+	}
+	if d := th.Locked("ip:127.0.0.1", "user:jdoe"); d != BaseLockout {
+		t.Errorf("Expected lockout of %v. Got %v", BaseLockout, d)
+	}
+
+	// Checking a lockout is not a request
+	if ok, _ := th.Allow("ip:127.0.0.1"); !ok {
+		t.Error("Request after checking lockout refused!")
+	}
+
+	th.Lock(MaxLockout, "ip:127.0.0.1")
+	th.Lock(BaseLockout, "ip:127.0.0.1")
+	if d := th.Locked("ip:127.0.0.1"); d != MaxLockout {
+		t.Errorf("Expected lockout of %v. Got %v", MaxLockout, d)
+	}
+
+	*now = now.Add(MaxLockout)
+	if d := th.Locked("ip:127.0.0.1", "user:jdoe"); d != 0 {
+		t.Errorf("Lockout outlasted itself by %v!", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if s := RetryAfter(1500 * time.Millisecond); s != 2 {
+		t.Errorf("Expected 2 seconds. Got %d", s)
+	}
+}
@@ -0,0 +1,207 @@
+// Package validation checks what is written to the inventory, whichever
+// client it comes from.
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Longest host name allowed by DNS (RFC 1035), and longest label in it.
+const maxHostNameLength = 253
+const maxLabelLength = 63
+
+// Rules are the rules server names must follow. Server names are DNS host
+// names: dot-separated labels of letters, digits and hyphens (RFC 1123).
+type Rules struct {
+	// MinLabels is the fewest labels a name may have, 2 for "host.domain".
+	MinLabels int `yaml:"min_labels"`
+	// MaxLength is the longest a name may be.
+	MaxLength int `yaml:"max_length"`
+	// AllowedSuffixes are the domains names must be in, if any.
+	AllowedSuffixes []string `yaml:"allowed_suffixes"`
+}
+
+// DefaultRules returns the default rules: any fully qualified host name.
+func DefaultRules() Rules {
+	return Rules{MinLabels: 2, MaxLength: maxHostNameLength}
+}
+
+// A Violation is one way in which a field breaks the rules.
+type Violation struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// Validate checks that the rules themselves make sense.
+func (r Rules) Validate() []string {
+	var errs []string
+	if r.MinLabels < 1 {
+		errs = append(errs, fmt.Sprintf("min_labels must be at least 1, not %d", r.MinLabels))
+	}
+	if r.MaxLength < 1 || r.MaxLength > maxHostNameLength {
+		errs = append(errs, fmt.Sprintf("max_length must be from 1 to %d, not %d", maxHostNameLength, r.MaxLength))
+	}
+	for _, suffix := range r.AllowedSuffixes {
+		if v := labelViolations("allowed_suffixes", normalizeSuffix(suffix)); len(v) > 0 {
+			errs = append(errs, fmt.Sprintf("allowed_suffixes: '%s' is not a domain name", suffix))
+		}
+	}
+	return errs
+}
+
+// normalize returns the canonical form of a host name: lower case, without
+// surrounding space or a trailing dot.
+func normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.TrimSuffix(name, ".")
+}
+
+// normalizeSuffix returns the canonical form of a domain, which may be given
+// with a leading dot.
+func normalizeSuffix(suffix string) string {
+	return strings.TrimPrefix(normalize(suffix), ".")
+}
+
+// ServerName returns the normalized server name, and every way in which it
+// breaks the rules.
+func (r Rules) ServerName(name string) (string, []Violation) {
+	const field = "name"
+	name = normalize(name)
+	if name == "" {
+		return name, []Violation{{field, "required", "A server name is required"}}
+	}
+
+	var violations []Violation
+	if len(name) > r.MaxLength {
+		violations = append(violations, Violation{field, "too_long",
+			fmt.Sprintf("Server names may be at most %d characters long", r.MaxLength)})
+	}
+	if n := strings.Count(name, ".") + 1; n < r.MinLabels {
+		violations = append(violations, Violation{field, "too_few_labels",
+			fmt.Sprintf("Server names must have at least %d dot-separated parts, such as host.example.com", r.MinLabels)})
+	}
+	violations = append(violations, labelViolations(field, name)...)
+
+	if len(r.AllowedSuffixes) > 0 && !r.allowedSuffix(name) {
+		violations = append(violations, Violation{field, "suffix_not_allowed",
+			fmt.Sprintf("Server names must be in one of these domains: %s", strings.Join(r.AllowedSuffixes, ", "))})
+	}
+	return name, violations
+}
+
+func (r Rules) allowedSuffix(name string) bool {
+	for _, suffix := range r.AllowedSuffixes {
+		suffix = normalizeSuffix(suffix)
+		if name == suffix || strings.HasSuffix(name, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// labelViolations checks each label of a normalized name against RFC 1123.
+func labelViolations(field, name string) []Violation {
+	var violations []Violation
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		switch {
+		case label == "":
+			violations = append(violations, Violation{field, "empty_label",
+				"Server names may not contain consecutive dots"})
+		case len(label) > maxLabelLength:
+			violations = append(violations, Violation{field, "label_too_long",
+				fmt.Sprintf("'%.10s...' is longer than %d characters", label, maxLabelLength)})
+		case !validLabel(label):
+			violations = append(violations, Violation{field, "invalid_label",
+				fmt.Sprintf("'%s' may only contain letters, digits and hyphens, and may not start or end with a hyphen", label)})
+		}
+	}
+	// An all-numeric top-level domain would make the name look like an IP address
+	if last := labels[len(labels)-1]; last != "" && strings.Trim(last, "0123456789") == "" {
+		violations = append(violations, Violation{field, "numeric_tld",
+			"Server names may not end in a number, IP addresses are not accepted"})
+	}
+	return violations
+}
+
+func validLabel(label string) bool {
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Statuses are the statuses a server may have.
+var Statuses = []string{"active", "maintenance", "decommissioned"}
+
+// DefaultStatus is the status of a new server, unless given another.
+const DefaultStatus = "active"
+
+// Status returns the normalized status, and how it breaks the rules.
+func Status(status string) (string, []Violation) {
+	const field = "status"
+	status = strings.ToLower(strings.TrimSpace(status))
+	for _, s := range Statuses {
+		if status == s {
+			return status, nil
+		}
+	}
+	return status, []Violation{{field, "invalid_status",
+		fmt.Sprintf("The status must be one of: %s", strings.Join(Statuses, ", "))}}
+}
+
+// Most tags a server may have, and longest a tag may be.
+const MaxTags = 20
+const maxTagLength = 50
+
+// TooManyTags is the violation of a server having more than MaxTags tags.
+func TooManyTags() Violation {
+	return Violation{"tags", "too_many_tags", fmt.Sprintf("A server may have at most %d tags", MaxTags)}
+}
+
+// Tags returns the normalized tags, sorted and without duplicates, and every
+// way in which they break the rules. Tags are lower case letters, digits,
+// hyphens and underscores, starting with a letter or digit.
+func Tags(tags []string) ([]string, []Violation) {
+	const field = "tags"
+	var violations []Violation
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+		if !validTag(tag) {
+			violations = append(violations, Violation{field, "invalid_tag",
+				fmt.Sprintf("'%s' is not a tag: tags are up to %d letters, digits, hyphens and underscores, starting with a letter or digit", tag, maxTagLength)})
+		}
+	}
+	if len(normalized) > MaxTags {
+		violations = append(violations, TooManyTags())
+	}
+	sort.Strings(normalized)
+	return normalized, violations
+}
+
+func validTag(tag string) bool {
+	if tag == "" || len(tag) > maxTagLength || tag[0] == '-' || tag[0] == '_' {
+		return false
+	}
+	for _, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
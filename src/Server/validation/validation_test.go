@@ -0,0 +1,97 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func codes(violations []Violation) string {
+	var c []string
+	for _, v := range violations {
+		c = append(c, v.Code)
+	}
+	return strings.Join(c, ",")
+}
+
+func TestServerName(t *testing.T) {
+	rules := DefaultRules()
+	for _, tc := range []struct {
+		name, normalized, codes string
+	}{
+		{"srv.example.com", "srv.example.com", ""},
+		{" Web-01.Prod.Example.COM. ", "web-01.prod.example.com", ""},
+		{"a.b.c.d.e.f", "a.b.c.d.e.f", ""},
+		{"", "", "required"},
+		{"localhost", "localhost", "too_few_labels"},
+		{"-1;example.com", "-1;example.com", "invalid_label"},
+		{"test server", "test server", "too_few_labels,invalid_label"},
+		{"srv..example.com", "srv..example.com", "empty_label"},
+		{"127.0.0.1", "127.0.0.1", "numeric_tld"},
+		{strings.Repeat("a", 64) + ".com", strings.Repeat("a", 64) + ".com", "label_too_long"},
+		{strings.Repeat("a.", 127) + "com", strings.Repeat("a.", 127) + "com", "too_long"},
+	} {
+		normalized, violations := rules.ServerName(tc.name)
+		if normalized != tc.normalized || codes(violations) != tc.codes {
+			t.Errorf("'%s' - Expected '%s' [%s]. Got '%s' [%s]", tc.name, tc.normalized, tc.codes, normalized, codes(violations))
+		}
+	}
+}
+
+func TestAllowedSuffixes(t *testing.T) {
+	rules := Rules{MinLabels: 1, MaxLength: 20, AllowedSuffixes: []string{"Example.com", ".example.org"}}
+	if errs := rules.Validate(); len(errs) > 0 {
+		t.Fatalf("Expected valid rules. Got %v", errs)
+	}
+
+	for name, want := range map[string]string{
+		"srv.example.com":          "",
+		"example.org":              "",
+		"srv.badexample.com":       "suffix_not_allowed",
+		"www.srv.example.com.evil": "too_long,suffix_not_allowed",
+	} {
+		if _, violations := rules.ServerName(name); codes(violations) != want {
+			t.Errorf("'%s' - Expected [%s]. Got [%s]", name, want, codes(violations))
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	rules := Rules{MinLabels: 0, MaxLength: 300, AllowedSuffixes: []string{"not a domain"}}
+	if errs := rules.Validate(); len(errs) != 3 {
+		t.Errorf("Expected 3 errors. Got %v", errs)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	for _, tc := range []struct {
+		status, normalized, codes string
+	}{
+		{"active", "active", ""},
+		{" Maintenance ", "maintenance", ""},
+		{"", "", "invalid_status"},
+		{"retired", "retired", "invalid_status"},
+	} {
+		normalized, violations := Status(tc.status)
+		if normalized != tc.normalized || codes(violations) != tc.codes {
+			t.Errorf("'%s' - Expected '%s' [%s]. Got '%s' [%s]", tc.status, tc.normalized, tc.codes, normalized, codes(violations))
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	for _, tc := range []struct {
+		tags              []string
+		normalized, codes string
+	}{
+		{nil, "", ""},
+		{[]string{"Rack-12", " db ", "rack-12", "eu_west"}, "db,eu_west,rack-12", ""},
+		{[]string{"", "-x", "a b", strings.Repeat("a", 51)}, ",-x,a b," + strings.Repeat("a", 51),
+			"invalid_tag,invalid_tag,invalid_tag,invalid_tag"},
+		{strings.Split("a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,r,s,t,u", ","), "a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,r,s,t,u", "too_many_tags"},
+	} {
+		normalized, violations := Tags(tc.tags)
+		if strings.Join(normalized, ",") != tc.normalized || codes(violations) != tc.codes {
+			t.Errorf("%v - Expected '%s' [%s]. Got %v [%s]", tc.tags, tc.normalized, tc.codes, normalized, codes(violations))
+		}
+	}
+}